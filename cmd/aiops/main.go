@@ -2,14 +2,31 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/voltic-software/aiops/internal/backup"
 	"github.com/voltic-software/aiops/internal/config"
 	"github.com/voltic-software/aiops/internal/evolve"
+	"github.com/voltic-software/aiops/internal/pack"
 	"github.com/voltic-software/aiops/internal/renderer"
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/memory"
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+	"github.com/voltic-software/aiops/internal/runtime/report"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
 	"github.com/voltic-software/aiops/internal/scanner"
 	"github.com/voltic-software/aiops/internal/skills"
 	"github.com/voltic-software/aiops/internal/target"
@@ -32,6 +49,8 @@ func main() {
 		cmdStatus()
 	case "update":
 		cmdUpdate()
+	case "diff":
+		cmdDiff()
 	case "evolve":
 		cmdEvolve()
 	case "skills":
@@ -42,6 +61,22 @@ func main() {
 		cmdDoctor()
 	case "uninstall":
 		cmdUninstall()
+	case "rollback":
+		cmdRollback()
+	case "schema":
+		cmdSchema()
+	case "run":
+		cmdRun()
+	case "specs":
+		cmdSpecs()
+	case "template":
+		cmdTemplate()
+	case "pack":
+		cmdPack()
+	case "memory":
+		cmdMemory()
+	case "review":
+		cmdReview()
 	case "version":
 		fmt.Printf("aiops %s\n", config.Version)
 	case "help", "--help", "-h":
@@ -62,15 +97,49 @@ Usage:
   aiops sync      Re-scan MCPs and targets, re-render rules (no questions)
   aiops status    Show what's installed and check for staleness
   aiops update    Regenerate artifacts from latest templates, show diff
-  aiops evolve    Read directive logs and propose rule changes
+  aiops diff      Show how installed artifacts differ from the latest templates
+  aiops evolve    Read directive logs and propose rule changes (--deep runs the LLM audit)
   aiops skills    Generate skill scaffolds from detected frameworks
   aiops doctor    Check integrity of aiops installation
   aiops uninstall Remove all aiops artifacts from this repository
+  aiops rollback  Undo the last init, sync or update: aiops rollback [id] (--list to show backups)
+  aiops schema    Print the JSON Schema for .aiops.yaml, or for workflow specs: aiops schema [config|spec] (--write to save it)
+  aiops run       Run a multiagency spec: aiops run <spec> --task "..."
+  aiops specs     Validate multiagency specs: aiops specs validate [spec...]
+  aiops template  Override built-in templates: aiops template eject <path> | list
+  aiops pack      Manage template packs: aiops pack add <dir|tarball> | list | remove <name>
+  aiops memory    List, prune or clear agent memory: aiops memory list|prune|clear [spec]
+  aiops review    Review the git diff with code_review.yaml: aiops review [--staged|--range A..B]
   aiops version   Show version
 
 Options:
   --dir <path>    Project directory (default: current directory)
-  --yes           Skip confirmation prompts (for uninstall)
+  --yes           Skip confirmation prompts (for update, uninstall and rollback)
+  --write         Write the schema to .aiops/aiops.schema.json or multiagency/specs/workflow.schema.json (for schema)
+  --task <text>   Task for the pipeline (for run)
+  --provider, --model <name>  Override the spec's LLM (for run and evolve --deep, e.g. --provider anthropic)
+  --format <fmt>  json (default), sarif, junit or markdown (for run)
+  -o <file>       Write the run result to a file instead of stdout
+  --verbose       Print each agent's output as it completes (for run)
+  --no-cache      Bypass the on-disk response cache (for run)
+  --no-memory     Neither recall nor save agent memory (for run)
+  --only <glob>   Only update matching files, e.g. "*.md" or ".windsurf/**" (for update; repeatable)
+  --exclude <glob>  Leave matching files alone (for update; repeatable)
+  -i, --interactive  Accept or reject each file in turn (for update)
+  --diff          Show the content diff of each change (for update)
+  --strategy <s>  Resolve merge conflicts with local edits: ours or theirs (for update; default: conflict markers)
+  --stat          Show changed line counts per file (for diff)
+  --name-only     Print only the paths of changed files (for diff)
+  --list          List the backups instead of restoring one (for rollback)
+  --force         Overwrite an existing override (for template eject)
+  --no-color      Disable colored diff output (also NO_COLOR)
+  --deep          Run the evolution_audit spec and merge its proposals (for evolve)
+  --staged        Review staged changes only (for review; default: all uncommitted changes)
+  --range <A..B>  Review a commit range, e.g. origin/main..HEAD (for review)
+  --fail-on <sev> Exit non-zero on findings this severe or worse: critical, high (default), medium, low, none
+  --agent <id>    Limit to one agent of the spec (for memory)
+  --older-than <d>  Prune entries not seen for this long, e.g. 720h (for memory prune)
+  --keep <n>      Keep at most n entries per agent (for memory prune)
   --help          Show this help`)
 }
 
@@ -154,35 +223,71 @@ func cmdInit() {
 	stack.Skills = skills
 	stack.Specs = specs
 
-	// 7. Build config
+	// 7. Build config. On a first init the standalone multiagency Go module is
+	// only generated for Go projects; everyone else runs specs with `aiops run`.
 	paths := config.DefaultPaths()
 	paths.Targets = targetNames
+	hasGo := false
+	for _, lang := range stack.Languages {
+		if lang.Name == "go" {
+			hasGo = true
+		}
+	}
 	cfg := &config.ProjectConfig{
 		Version: config.Version,
 		Project: config.Project{
 			Name:     projectName,
 			Maturity: maturity,
 		},
-		Paths:    paths,
-		Detected: *stack,
+		Paths:       paths,
+		Detected:    *stack,
+		Multiagency: config.Multiagency{SkipModule: !hasGo},
+	}
+	// Keep the settings a re-init cannot detect. Only the IDE targets are
+	// detected again; the output locations stay where the user put them.
+	if old, err := config.Load(dir); err == nil {
+		if old.Paths.Windsurf != "" {
+			cfg.Paths.Windsurf = old.Paths.Windsurf
+		}
+		if old.Paths.Multiagency != "" {
+			cfg.Paths.Multiagency = old.Paths.Multiagency
+		}
+		cfg.Editor = old.Editor
+		cfg.Managed = old.Managed
+		cfg.Backups = old.Backups
+		cfg.Templates = old.Templates
+		cfg.Packs = old.Packs
+		cfg.Rules = old.Rules
+		cfg.Vars = old.Vars
+		cfg.Multiagency = old.Multiagency
+	}
+
+	// 8. Back up what init is about to overwrite. Rendering first also
+	// stops a template error before anything is written.
+	out, err := renderer.RenderFiles(dir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering templates: %v\n", err)
+		os.Exit(1)
 	}
+	backupBefore(dir, cfg, "init", filePaths(out.Files))
 
-	// 8. Save config
+	// 9. Save config
 	if err := config.Save(dir, cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("✓ Created .aiops.yaml\n")
 
-	// 9. Render templates (first pass — generates base specs)
+	// 10. Render templates (first pass — generates base specs)
 	fmt.Println("\nGenerating artifacts...")
 	files, err := renderer.RenderAll(dir, cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error rendering templates: %v\n", err)
+		printPartial(cfg, files)
 		os.Exit(1)
 	}
 
-	// 10. Re-detect specs after first render (picks up newly generated specs)
+	// 11. Re-detect specs after first render (picks up newly generated specs)
 	newSpecs := scanner.DetectSpecs(dir)
 	if len(newSpecs) > len(specs) {
 		cfg.Detected.Specs = newSpecs
@@ -190,6 +295,7 @@ func cmdInit() {
 		files2, err := renderer.RenderAll(dir, cfg)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error re-rendering with specs: %v\n", err)
+			printPartial(cfg, files2)
 			os.Exit(1)
 		}
 		files = files2
@@ -276,7 +382,109 @@ func cmdStatus() {
 	detectedSpecs := scanner.DetectSpecs(dir)
 	fmt.Printf("Workflows:  %d\n", len(detectedSpecs))
 
-	// Check which artifacts exist
+	manifest, err := renderer.LoadManifest(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if manifest != nil && len(manifest.Files) > 0 {
+		printManifestStatus(dir, manifest)
+	} else {
+		printArtifactStatus(dir, cfg)
+	}
+
+	// Re-scan and compare
+	fmt.Println("\nDrift check...")
+	stack, err := scanner.Scan(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: scan failed: %v\n", err)
+		return
+	}
+
+	diffs := compareStack(&cfg.Detected, stack)
+	if len(diffs) == 0 {
+		fmt.Println("✓ No drift detected")
+	} else {
+		fmt.Println("⚠  Stack drift detected:")
+		for _, d := range diffs {
+			fmt.Printf("  - %s\n", d)
+		}
+		fmt.Println("\nRun `aiops sync` to update.")
+	}
+
+	// Compare installed artifacts with the latest templates
+	if plan, err := updater.ComputePlan(dir, cfg); err == nil {
+		if changes := plan.Changes(); len(changes) == 0 {
+			fmt.Println("✓ Artifacts match the latest templates")
+		} else {
+			fmt.Printf("⚠  %d artifacts differ from the latest templates:\n", len(changes))
+			printDiffStat(changes)
+			fmt.Println("\nRun `aiops diff` to see the changes, `aiops update` to apply them.")
+		}
+	}
+
+	// Check orchestrator state
+	statePath := filepath.Join(dir, cfg.Paths.Windsurf, "orchestrator", "session_state.yaml")
+	if _, err := os.Stat(statePath); err == nil {
+		fmt.Println("\nOrchestrator: active")
+	}
+}
+
+// printManifestStatus summarizes the files in the manifest per target.
+func printManifestStatus(dir string, manifest *renderer.Manifest) {
+	type summary struct {
+		files, missing, modified int
+		problems                 []string
+	}
+	var order []string
+	byTarget := map[string]*summary{}
+	for i := range manifest.Files {
+		entry := &manifest.Files[i]
+		sum := byTarget[entry.Target]
+		if sum == nil {
+			sum = &summary{}
+			byTarget[entry.Target] = sum
+			order = append(order, entry.Target)
+		}
+		sum.files++
+		switch entry.Check(dir) {
+		case renderer.StateMissing:
+			sum.missing++
+			sum.problems = append(sum.problems, entry.Path+" (missing)")
+		case renderer.StateModified:
+			// Seeds and user-owned files are meant to be edited
+			if entry.Rewritten() {
+				sum.modified++
+				sum.problems = append(sum.problems, entry.Path+" (modified locally)")
+			}
+		}
+	}
+	slices.Sort(order)
+
+	fmt.Printf("\nArtifacts (%s):\n", renderer.ManifestPath)
+	installed, missing := 0, 0
+	for _, name := range order {
+		sum := byTarget[name]
+		installed += sum.files - sum.missing
+		missing += sum.missing
+		symbol, detail := "✓", ""
+		if sum.modified > 0 {
+			symbol, detail = "⚠", fmt.Sprintf(", %d modified locally", sum.modified)
+		}
+		if sum.missing > 0 {
+			symbol, detail = "✗", fmt.Sprintf(", %d missing", sum.missing)+detail
+		}
+		fmt.Printf("  %s %-10s %d files%s\n", symbol, name, sum.files, detail)
+		for _, problem := range sum.problems {
+			fmt.Printf("      %s\n", problem)
+		}
+	}
+
+	fmt.Printf("\n%d installed, %d missing\n", installed, missing)
+}
+
+// printArtifactStatus checks the well-known artifacts of a project
+// installed before aiops kept a manifest.
+func printArtifactStatus(dir string, cfg *config.ProjectConfig) {
 	type artifact struct {
 		path  string
 		label string
@@ -311,31 +519,7 @@ func cmdStatus() {
 	}
 
 	fmt.Printf("\n%d installed, %d missing\n", installed, missing)
-
-	// Re-scan and compare
-	fmt.Println("\nDrift check...")
-	stack, err := scanner.Scan(dir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: scan failed: %v\n", err)
-		return
-	}
-
-	diffs := compareStack(&cfg.Detected, stack)
-	if len(diffs) == 0 {
-		fmt.Println("✓ No drift detected")
-	} else {
-		fmt.Println("⚠  Stack drift detected:")
-		for _, d := range diffs {
-			fmt.Printf("  - %s\n", d)
-		}
-		fmt.Println("\nRun `aiops sync` to update.")
-	}
-
-	// Check orchestrator state
-	statePath := filepath.Join(dir, cfg.Paths.Windsurf, "orchestrator", "session_state.yaml")
-	if _, err := os.Stat(statePath); err == nil {
-		fmt.Println("\nOrchestrator: active")
-	}
+	fmt.Println("Run `aiops sync` to record a manifest of the generated files.")
 }
 
 // --- update command ---
@@ -349,6 +533,12 @@ func cmdUpdate() {
 		os.Exit(1)
 	}
 
+	strategy := flagValue("--strategy")
+	if !updater.ValidStrategy(strategy) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --strategy %q (use ours or theirs)\n", strategy)
+		os.Exit(1)
+	}
+
 	fmt.Printf("aiops update — %s\n\n", cfg.Project.Name)
 	fmt.Println("Computing diff against latest templates...")
 
@@ -357,36 +547,278 @@ func cmdUpdate() {
 		fmt.Fprintf(os.Stderr, "Error computing plan: %v\n", err)
 		os.Exit(1)
 	}
+	plan.Resolve(strategy)
 
-	if plan.NewFiles == 0 && plan.Modified == 0 {
+	if len(plan.Changes()) == 0 {
 		fmt.Println("✓ All artifacts are up to date. No changes needed.")
 		return
 	}
 
-	fmt.Printf("\nUpdate plan: %d new, %d modified, %d unchanged\n\n", plan.NewFiles, plan.Modified, plan.Unchanged)
-
-	for _, diff := range plan.Diffs {
-		switch diff.Status {
-		case "new":
-			fmt.Printf("  + %s (new)\n", diff.Path)
-		case "modified":
-			fmt.Printf("  ~ %s (modified)\n", diff.Path)
-		}
+	summary := fmt.Sprintf("%d new, %d modified", plan.NewFiles, plan.Modified)
+	if plan.Merged > 0 {
+		summary += fmt.Sprintf(", %d merged", plan.Merged)
+	}
+	if plan.Conflicted > 0 {
+		summary += fmt.Sprintf(", %d conflicting", plan.Conflicted)
+	}
+	if plan.Removed > 0 {
+		summary += fmt.Sprintf(", %d obsolete", plan.Removed)
+	}
+	summary += fmt.Sprintf(", %d unchanged", plan.Unchanged)
+	if len(plan.Skipped) > 0 {
+		summary += fmt.Sprintf(", %d team-owned seeds kept", len(plan.Skipped))
 	}
+	fmt.Printf("\nUpdate plan: %s\n\n", summary)
 
-	fmt.Println()
-	if !confirm("Apply these changes?") {
-		fmt.Println("Aborted.")
+	changes := updater.Filter(plan.Changes(), flagValues("--only"), flagValues("--exclude"))
+	if len(changes) == 0 {
+		fmt.Println("✓ No changes match --only/--exclude.")
 		return
 	}
 
-	files, err := renderer.RenderAll(dir, cfg)
+	showDiff := hasFlag("--diff")
+	if hasFlag("--interactive", "-i") {
+		changes = selectChanges(changes, showDiff)
+		if len(changes) == 0 {
+			fmt.Println("\nNothing selected.")
+			return
+		}
+	} else {
+		for _, diff := range changes {
+			printChange(diff)
+		}
+		if showDiff {
+			fmt.Println()
+			printDiffs(changes)
+		}
+		fmt.Println()
+		if !hasFlag("--yes") && !confirm("Apply these changes?") {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	backupBefore(dir, cfg, "update", diffPaths(changes))
+	files, err := updater.Apply(dir, cfg, changes)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error applying update: %v\n", err)
+		printPartial(cfg, files)
 		os.Exit(1)
 	}
 
 	fmt.Printf("\n✅ Updated %d files.\n", len(files))
+	printConflicts(changes)
+}
+
+// printPartial lists the files an operation wrote before it failed. They
+// are recorded in the manifest, and the backup taken first undoes them.
+func printPartial(cfg *config.ProjectConfig, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d files were written before the error; the rest are unchanged:\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(os.Stderr, "  ✓ %s\n", f)
+	}
+	if cfg.Backups.Limit() != 0 {
+		fmt.Fprintln(os.Stderr, "Run `aiops rollback` to undo them.")
+	}
+}
+
+// backupBefore saves the files an operation is about to overwrite, so
+// `aiops rollback` can undo it. The operation stops when the backup fails.
+func backupBefore(dir string, cfg *config.ProjectConfig, operation string, paths []string) {
+	keep := cfg.Backups.Limit()
+	if keep == 0 {
+		return
+	}
+	rec, err := backup.Create(dir, operation, paths)
+	if err == nil {
+		err = backup.Prune(dir, keep)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error backing up: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Backed up to %s/%s (undo with `aiops rollback`)\n", backup.Dir, rec.ID)
+}
+
+// filePaths returns the paths of rendered files.
+func filePaths(files []renderer.File) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+// diffPaths returns the paths of diffs.
+func diffPaths(diffs []updater.Diff) []string {
+	paths := make([]string, len(diffs))
+	for i, diff := range diffs {
+		paths[i] = diff.Path
+	}
+	return paths
+}
+
+// printConflicts lists the files written with conflict markers.
+func printConflicts(changes []updater.Diff) {
+	var conflicted []string
+	for _, diff := range changes {
+		if diff.Status == "conflict" {
+			conflicted = append(conflicted, diff.Path)
+		}
+	}
+	if len(conflicted) == 0 {
+		return
+	}
+	fmt.Printf("\n⚠ %d files have conflict markers where local edits and template changes overlap:\n", len(conflicted))
+	for _, path := range conflicted {
+		fmt.Printf("  ! %s\n", path)
+	}
+	fmt.Println("\nEdit them to resolve the markers. To pick a side without markers, run update with --strategy ours|theirs.")
+}
+
+func printChange(diff updater.Diff) {
+	switch diff.Status {
+	case "new":
+		fmt.Printf("  + %s (new)\n", diff.Path)
+	case "modified":
+		fmt.Printf("  ~ %s (modified)\n", diff.Path)
+	case "merged":
+		fmt.Printf("  ~ %s (merged with local edits)\n", diff.Path)
+	case "conflict":
+		fmt.Printf("  ! %s (conflicting hunks: %d)\n", diff.Path, diff.Conflicts)
+	case "removed":
+		fmt.Printf("  - %s (obsolete, will be removed)\n", diff.Path)
+	}
+}
+
+// selectChanges asks about each change in turn: y applies it, n skips it,
+// a applies it and every remaining one, q skips the rest. With showDiff
+// each file's diff is printed before the question.
+func selectChanges(changes []updater.Diff, showDiff bool) []updater.Diff {
+	reader := bufio.NewReader(os.Stdin)
+	var selected []updater.Diff
+	for i, diff := range changes {
+		printChange(diff)
+		if showDiff {
+			printDiffs(changes[i : i+1])
+		}
+		fmt.Print("    Apply? [y/n/a/q] ")
+		input, _ := reader.ReadString('\n')
+		switch strings.TrimSpace(strings.ToLower(input)) {
+		case "y", "yes":
+			selected = append(selected, diff)
+		case "a", "all":
+			return append(selected, changes[i:]...)
+		case "q", "quit":
+			return selected
+		}
+	}
+	return selected
+}
+
+// --- diff command ---
+
+func cmdDiff() {
+	dir := getDir()
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		fmt.Println("✗ Not initialized. Run `aiops init` first.")
+		os.Exit(1)
+	}
+
+	plan, err := updater.ComputePlan(dir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error computing plan: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Positional arguments narrow the diff like --only
+	only := append(positionalArgs(2), flagValues("--only")...)
+	changes := updater.Filter(plan.Changes(), only, flagValues("--exclude"))
+
+	switch {
+	case hasFlag("--name-only"):
+		for _, diff := range changes {
+			fmt.Println(diff.Path)
+		}
+	case hasFlag("--stat"):
+		printDiffStat(changes)
+	default:
+		printDiffs(changes)
+	}
+}
+
+// printDiffs prints the unified diff of each change, colored on a terminal.
+func printDiffs(changes []updater.Diff) {
+	color := useColor()
+	for _, diff := range changes {
+		for _, line := range strings.SplitAfter(diff.Unified(updater.DiffContext), "\n") {
+			if line == "" {
+				continue
+			}
+			if !color {
+				fmt.Print(line)
+				continue
+			}
+			code := ""
+			switch {
+			case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+				code = "1"
+			case strings.HasPrefix(line, "@@"):
+				code = "36"
+			case strings.HasPrefix(line, "+"):
+				code = "32"
+			case strings.HasPrefix(line, "-"):
+				code = "31"
+			}
+			if code == "" {
+				fmt.Print(line)
+			} else {
+				fmt.Printf("\033[%sm%s\033[0m\n", code, strings.TrimSuffix(line, "\n"))
+			}
+		}
+	}
+}
+
+// printDiffStat prints a git-style summary of added and removed lines.
+func printDiffStat(changes []updater.Diff) {
+	if len(changes) == 0 {
+		return
+	}
+	color := useColor()
+	width := 0
+	for _, diff := range changes {
+		width = max(width, len(diff.Path))
+	}
+
+	var added, removed int
+	for _, diff := range changes {
+		stat := diff.Stat()
+		added += stat.Added
+		removed += stat.Removed
+		plus := strings.Repeat("+", min(stat.Added, 40))
+		minus := strings.Repeat("-", min(stat.Removed, 40))
+		if color {
+			plus = "\033[32m" + plus + "\033[0m"
+			minus = "\033[31m" + minus + "\033[0m"
+		}
+		fmt.Printf(" %-*s | %5d %s%s\n", width, diff.Path, stat.Added+stat.Removed, plus, minus)
+	}
+	fmt.Printf(" %d files changed, %d insertions(+), %d deletions(-)\n", len(changes), added, removed)
+}
+
+// useColor reports whether diff output should be colored: stdout is a
+// terminal and neither --no-color nor NO_COLOR is set.
+func useColor() bool {
+	if hasFlag("--no-color") || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 // --- evolve command ---
@@ -407,6 +839,11 @@ func cmdEvolve() {
 
 	fmt.Printf("aiops evolve — analyzing directive logs for %s\n\n", cfg.Project.Name)
 
+	if hasFlag("--deep") {
+		evolveDeep(dir, windsurfDir)
+		return
+	}
+
 	patterns, err := evolve.AnalyzeDirectives(dir, windsurfDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -432,6 +869,91 @@ func cmdEvolve() {
 	}
 }
 
+// evolveDeep runs the evolution_audit spec with the directive log, completed
+// sessions and repo rules as context, and merges its proposals with the
+// rule-based patterns into one report.
+func evolveDeep(dir, windsurfDir string) {
+	state, err := evolve.LoadState(dir, windsurfDir)
+	if err != nil {
+		fmt.Printf("⚠ %v — auditing without a directive log\n\n", err)
+		state = &evolve.SessionState{}
+	}
+	patterns := evolve.DetectPatterns(state.DirectiveLog)
+
+	specPath := resolveSpec(dir, "evolution_audit")
+	workflowSpec, err := spec.LoadFromFile(specPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+		os.Exit(1)
+	}
+	workflowSpec.LLM.Override(flagValue("--provider"), flagValue("--model"))
+
+	executor, err := newExecutor(dir, workflowSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	inputs := map[string]interface{}{
+		"directive_log":      state.DirectiveLog,
+		"completed_sessions": state.CompletedSessions,
+	}
+	var repoRules []map[string]string
+	seen := map[string]bool{}
+	for _, t := range target.Detect(dir) {
+		path := t.ResolveRepoRulesPath(dir)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if content, err := os.ReadFile(path); err == nil {
+			repoRules = append(repoRules, map[string]string{"target": t.Name, "path": t.RepoRulesPath, "content": string(content)})
+		}
+	}
+	if len(repoRules) > 0 {
+		inputs["repo_rules"] = repoRules
+	}
+	executor.SetInputs(inputs)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// The spec is seeded once, so instructions that must reach existing
+	// projects go in the task rather than in the spec's constraints
+	task := "Audit how this codebase and its agent rules have evolved since the last audit. " +
+		"The directive log, completed sessions and the current repo rules of every target are provided as context; " +
+		"use them instead of reading session_state.yaml or the rules files."
+	result, err := executor.Execute(ctx, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	outputs := map[string]map[string]interface{}{}
+	for id, r := range result.AllOutputs {
+		outputs[id] = r.Output
+	}
+	audit, err := evolve.ParseAudit(outputs["discovery"], outputs["proposal"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading audit: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := evolve.GenerateDeepReport(patterns, len(state.DirectiveLog), audit)
+	fmt.Println()
+	fmt.Println(report)
+
+	reportPath := filepath.Join(dir, windsurfDir, "orchestrator", "evolution_report.md")
+	if err := os.WriteFile(reportPath, []byte(report), 0644); err == nil {
+		fmt.Printf("Report saved to: %s\n", reportPath)
+	}
+
+	if result.Status == pipeline.StatusCancelled {
+		fmt.Fprintf(os.Stderr, "⚠ Pipeline %s\n", result.Error)
+		os.Exit(1)
+	}
+}
+
 // --- skills command ---
 
 func cmdSkills() {
@@ -606,20 +1128,43 @@ func cmdSync() {
 	cfg.Paths.Targets = targetNames
 	cfg.Project.Maturity = newMaturity
 
+	// Re-render all artifacts, keeping local edits. Files whose edits
+	// conflict with template changes are left for `aiops update`, so their
+	// merge base stays in place.
+	fmt.Println("\nRe-rendering artifacts...")
+	plan, err := updater.ComputePlan(dir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering: %v\n", err)
+		os.Exit(1)
+	}
+
+	var render []updater.Diff
+	for _, diff := range plan.Diffs {
+		if diff.Status != "removed" && diff.Status != "conflict" {
+			render = append(render, diff)
+		}
+	}
+	backupBefore(dir, cfg, "sync", diffPaths(render))
+
 	if err := config.Save(dir, cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Re-render all artifacts
-	fmt.Println("\nRe-rendering artifacts...")
-	files, err := renderer.RenderAll(dir, cfg)
+	files, err := updater.Apply(dir, cfg, render)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error rendering: %v\n", err)
+		printPartial(cfg, files)
 		os.Exit(1)
 	}
 
 	fmt.Printf("\n✅ Synced. %d files rendered.\n", len(files))
+	if plan.Merged > 0 {
+		fmt.Printf("   %d locally edited files were merged with the template changes.\n", plan.Merged)
+	}
+	if plan.Conflicted > 0 {
+		fmt.Printf("⚠ %d locally edited files conflict with template changes and were left alone — run `aiops update` to merge them.\n", plan.Conflicted)
+	}
 }
 
 // --- uninstall command ---
@@ -655,60 +1200,105 @@ func cmdUninstall() {
 		removals = append(removals, removal{configPath, ".aiops.yaml", false})
 	}
 
-	// .aiops/ directory (soul.md, soul.local.md, kill switch)
-	aiopsDir := filepath.Join(dir, ".aiops")
-	if _, err := os.Stat(aiopsDir); err == nil {
-		removals = append(removals, removal{aiopsDir, ".aiops/", true})
+	manifest, err := renderer.LoadManifest(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// decisions/ directory (only if it contains the seed file and nothing else)
-	decisionsDir := filepath.Join(dir, "decisions")
-	if entries, err := os.ReadDir(decisionsDir); err == nil {
-		if len(entries) == 1 && entries[0].Name() == "0001-aiops-initialized.md" {
-			removals = append(removals, removal{decisionsDir, "decisions/ (seed only)", true})
+	var kept, stripped []string
+	if len(manifest.Files) > 0 {
+		// Remove what the manifest says aiops owns; seeds only while unchanged
+		for i := range manifest.Files {
+			entry := &manifest.Files[i]
+			state := entry.Check(dir)
+			if state == renderer.StateMissing {
+				continue
+			}
+			if entry.Ownership == renderer.OwnerManaged {
+				stripped = append(stripped, entry.Path)
+				continue
+			}
+			if entry.Ownership == renderer.OwnerUser || (entry.Ownership == renderer.OwnerSeedOnce && state == renderer.StateModified) {
+				kept = append(kept, entry.Path)
+				continue
+			}
+			removals = append(removals, removal{filepath.Join(dir, entry.Path), entry.Path, false})
 		}
-	}
-
-	// multiagency/ directory
-	multiagencyDir := cfg.Paths.Multiagency
-	if multiagencyDir == "" {
-		multiagencyDir = "multiagency"
-	}
-	multiagencyPath := filepath.Join(dir, multiagencyDir)
-	if _, err := os.Stat(multiagencyPath); err == nil {
-		removals = append(removals, removal{multiagencyPath, multiagencyDir + "/", true})
-	}
 
-	// Per-target artifacts
-	targets := target.Detect(dir)
-	for _, t := range targets {
-		// Repo rules file
-		if t.RepoRulesPath != "" {
-			p := filepath.Join(dir, t.RepoRulesPath)
-			if _, err := os.Stat(p); err == nil {
-				removals = append(removals, removal{p, t.RepoRulesPath, false})
+		// The rest of .aiops/ is aiops state (manifest, memory, kill switch),
+		// except the template overrides the team wrote
+		aiopsDir := filepath.Join(dir, ".aiops")
+		entries, _ := os.ReadDir(aiopsDir)
+		for _, e := range entries {
+			rel := filepath.Join(".aiops", e.Name())
+			if rel == filepath.FromSlash(renderer.OverridesDir) {
+				kept = append(kept, rel+string(filepath.Separator))
+				continue
 			}
-		}
-		// Workflows dir (only aiops-generated files)
-		if t.WorkflowsDir != "" {
-			wfDir := filepath.Join(dir, t.WorkflowsDir)
-			for _, name := range []string{"default-mode.md", "multiagency.md", "orchestrator.md"} {
-				p := filepath.Join(wfDir, name)
-				if _, err := os.Stat(p); err == nil {
-					removals = append(removals, removal{p, filepath.Join(t.WorkflowsDir, name), false})
-				}
+			if manifest.Lookup(rel) != nil || slices.ContainsFunc(kept, func(k string) bool {
+				return k == rel || strings.HasPrefix(k, rel+string(filepath.Separator))
+			}) {
+				continue
 			}
+			removals = append(removals, removal{filepath.Join(dir, rel), rel, e.IsDir()})
 		}
-		// Orchestrator dir
-		if t.OrchestrDir != "" {
-			p := filepath.Join(dir, t.OrchestrDir)
-			if _, err := os.Stat(p); err == nil {
-				removals = append(removals, removal{p, t.OrchestrDir + "/", true})
+	} else {
+		// .aiops/ directory (soul.md, soul.local.md, kill switch)
+		aiopsDir := filepath.Join(dir, ".aiops")
+		if _, err := os.Stat(aiopsDir); err == nil {
+			removals = append(removals, removal{aiopsDir, ".aiops/", true})
+		}
+
+		// decisions/ directory (only if it contains the seed file and nothing else)
+		decisionsDir := filepath.Join(dir, "decisions")
+		if entries, err := os.ReadDir(decisionsDir); err == nil {
+			if len(entries) == 1 && entries[0].Name() == "0001-aiops-initialized.md" {
+				removals = append(removals, removal{decisionsDir, "decisions/ (seed only)", true})
+			}
+		}
+
+		// multiagency/ directory
+		multiagencyDir := cfg.Paths.Multiagency
+		if multiagencyDir == "" {
+			multiagencyDir = "multiagency"
+		}
+		multiagencyPath := filepath.Join(dir, multiagencyDir)
+		if _, err := os.Stat(multiagencyPath); err == nil {
+			removals = append(removals, removal{multiagencyPath, multiagencyDir + "/", true})
+		}
+
+		// Per-target artifacts
+		targets := target.Detect(dir)
+		for _, t := range targets {
+			// Repo rules file
+			if t.RepoRulesPath != "" {
+				p := filepath.Join(dir, t.RepoRulesPath)
+				if _, err := os.Stat(p); err == nil {
+					removals = append(removals, removal{p, t.RepoRulesPath, false})
+				}
+			}
+			// Workflows dir (only aiops-generated files)
+			if t.WorkflowsDir != "" {
+				wfDir := filepath.Join(dir, t.WorkflowsDir)
+				for _, name := range []string{"default-mode.md", "multiagency.md", "orchestrator.md"} {
+					p := filepath.Join(wfDir, name)
+					if _, err := os.Stat(p); err == nil {
+						removals = append(removals, removal{p, filepath.Join(t.WorkflowsDir, name), false})
+					}
+				}
+			}
+			// Orchestrator dir
+			if t.OrchestrDir != "" {
+				p := filepath.Join(dir, t.OrchestrDir)
+				if _, err := os.Stat(p); err == nil {
+					removals = append(removals, removal{p, t.OrchestrDir + "/", true})
+				}
 			}
 		}
 	}
 
-	if len(removals) == 0 {
+	if len(removals) == 0 && len(stripped) == 0 {
 		fmt.Println("Nothing to remove.")
 		return
 	}
@@ -719,6 +1309,18 @@ func cmdUninstall() {
 	for _, r := range removals {
 		fmt.Printf("  - %s\n", r.label)
 	}
+	if len(stripped) > 0 {
+		fmt.Println("\nThe aiops block will be removed from (files deleted if nothing else is left):")
+		for _, path := range stripped {
+			fmt.Printf("  - %s\n", path)
+		}
+	}
+	if len(kept) > 0 {
+		fmt.Println("\nKept (edited seeds and user-owned files):")
+		for _, path := range kept {
+			fmt.Printf("  - %s\n", path)
+		}
+	}
 	fmt.Println("\nGlobal tools and binaries will NOT be removed.")
 
 	if !autoConfirm {
@@ -746,9 +1348,154 @@ func cmdUninstall() {
 		}
 	}
 
+	// Take the managed block out of files shared with hand-written content
+	for _, rel := range stripped {
+		path := filepath.Join(dir, rel)
+		content, err := os.ReadFile(path)
+		if err == nil {
+			rest, _ := renderer.StripManaged(content)
+			if strings.TrimSpace(string(rest)) == "" {
+				err = os.Remove(path)
+				removals = append(removals, removal{path, rel, false})
+			} else {
+				err = os.WriteFile(path, rest, 0644)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ✗ Failed to remove the aiops block from %s: %v\n", rel, err)
+		} else {
+			fmt.Printf("  ✓ Removed the aiops block from %s\n", rel)
+			removed++
+		}
+	}
+
+	// Drop directories the removed files leave empty
+	for _, r := range removals {
+		for parent := filepath.Dir(r.path); parent != dir && strings.HasPrefix(parent, dir); parent = filepath.Dir(parent) {
+			if os.Remove(parent) != nil {
+				break
+			}
+		}
+	}
+
 	fmt.Printf("\n✅ AIops uninstalled. %d items removed.\n", removed)
 }
 
+// --- rollback command ---
+
+// cmdRollback restores the backup taken before an init, sync or update:
+// the newest one, or the one named by its ID.
+func cmdRollback() {
+	dir := getDir()
+
+	records, err := backup.List(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if hasFlag("--list") {
+		if len(records) == 0 {
+			fmt.Println("No backups.")
+			return
+		}
+		for _, rec := range records {
+			fmt.Printf("  %-18s  %-8s  %s  aiops %s  %d files\n",
+				rec.ID, rec.Operation, rec.Created.Format("2006-01-02 15:04"), rec.Version, len(projectEntries(rec)))
+		}
+		return
+	}
+
+	var rec *backup.Record
+	if args := positionalArgs(2); len(args) > 0 {
+		rec, err = backup.Load(dir, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if len(records) > 0 {
+		rec = &records[0]
+	} else {
+		fmt.Println("✗ No backups to roll back to.")
+		os.Exit(1)
+	}
+
+	fmt.Printf("aiops rollback — %s (before %s, %s)\n\n", rec.ID, rec.Operation, rec.Created.Format("2006-01-02 15:04"))
+	for _, e := range projectEntries(*rec) {
+		if e.Existed {
+			fmt.Printf("  ↺ %s\n", e.Path)
+		} else if _, err := os.Stat(filepath.Join(dir, e.Path)); err == nil {
+			fmt.Printf("  - %s (created by %s)\n", e.Path, rec.Operation)
+		}
+	}
+	fmt.Println()
+	if !hasFlag("--yes") && !confirm("Restore these files?") {
+		fmt.Println("Aborted.")
+		return
+	}
+
+	// Snapshot the current state first so the rollback can be undone too
+	keep := config.DefaultBackupKeep
+	if cfg, err := config.Load(dir); err == nil {
+		keep = cfg.Backups.Limit()
+	}
+	if keep != 0 {
+		var paths []string
+		for _, e := range projectEntries(*rec) {
+			paths = append(paths, e.Path)
+		}
+		undo, err := backup.Create(dir, "rollback", paths)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error backing up: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Backed up to %s/%s (undo with `aiops rollback %s`)\n", backup.Dir, undo.ID, undo.ID)
+	}
+
+	restored, removed, err := backup.Restore(dir, rec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rolling back: %v\n", err)
+		if n := countProject(restored) + countProject(removed); n > 0 {
+			fmt.Fprintf(os.Stderr, "%d files were already rolled back; the rest are unchanged.\n", n)
+		}
+		os.Exit(1)
+	}
+	if keep != 0 {
+		if err := backup.Prune(dir, keep); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	fmt.Printf("✅ Rolled back %s: %d files restored, %d removed.\n", rec.Operation, countProject(restored), countProject(removed))
+}
+
+// projectEntries returns the entries of a backup without the merge bases,
+// which are restored along with their files.
+func projectEntries(rec backup.Record) []backup.Entry {
+	var entries []backup.Entry
+	for _, e := range rec.Files {
+		if !isBasePath(e.Path) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// countProject counts the paths that are not merge bases.
+func countProject(paths []string) int {
+	n := 0
+	for _, p := range paths {
+		if !isBasePath(p) {
+			n++
+		}
+	}
+	return n
+}
+
+// isBasePath reports whether a project-relative path is a merge base.
+func isBasePath(path string) bool {
+	return strings.HasPrefix(filepath.ToSlash(path), renderer.BaseDir+"/")
+}
+
 // --- doctor command ---
 
 func cmdDoctor() {
@@ -782,13 +1529,17 @@ func cmdDoctor() {
 	}
 
 	// 1. Config
-	pass(".aiops.yaml")
+	if err := cfg.Rules.Modules.Validate(); err != nil {
+		fail(".aiops.yaml", err.Error())
+	} else {
+		pass(".aiops.yaml")
+	}
 
 	// 2. Soul
 	soulPath := filepath.Join(dir, ".aiops", "soul.md")
 	if _, err := os.Stat(soulPath); err == nil {
 		// Verify it matches the canonical soul (not tampered)
-		canonical, readErr := renderer.GetTemplateFS().ReadFile("templates/soul/soul.md")
+		canonical, readErr := fs.ReadFile(renderer.Templates(dir, cfg), "templates/soul/soul.md")
 		if readErr == nil {
 			actual, _ := os.ReadFile(soulPath)
 			if string(actual) == string(canonical) {
@@ -819,42 +1570,66 @@ func cmdDoctor() {
 		pass("kill switch (inactive)")
 	}
 
-	// 5. Repo rules per target
-	targets := target.Detect(dir)
-	for _, t := range targets {
-		if t.RepoRulesPath != "" {
-			p := filepath.Join(dir, t.RepoRulesPath)
+	// 5. Generated artifacts, from the manifest when there is one
+	manifest, err := renderer.LoadManifest(dir)
+	if err != nil {
+		fail(renderer.ManifestPath, err.Error())
+	} else if len(manifest.Files) > 0 {
+		pass(fmt.Sprintf("manifest (%d files)", len(manifest.Files)))
+		for i := range manifest.Files {
+			entry := &manifest.Files[i]
+			// Seeds and user-owned files are the team's to edit or delete;
+			// soul.md was checked above
+			if !entry.Rewritten() || entry.Path == filepath.Join(".aiops", "soul.md") {
+				continue
+			}
+			switch entry.Check(dir) {
+			case renderer.StateMissing:
+				fail(entry.Path, "missing")
+			case renderer.StateModified:
+				warn(entry.Path, "modified locally — `aiops update` keeps the edits where templates did not change")
+			}
+		}
+	} else {
+		warn("manifest", "not found — run `aiops sync` to record the generated files")
+
+		// Repo rules per target
+		targets := target.Detect(dir)
+		for _, t := range targets {
+			if t.RepoRulesPath != "" {
+				p := filepath.Join(dir, t.RepoRulesPath)
+				if _, err := os.Stat(p); err == nil {
+					pass(fmt.Sprintf("repo rules (%s)", t.DisplayName))
+				} else {
+					fail(fmt.Sprintf("repo rules (%s)", t.DisplayName), "missing")
+				}
+			}
+		}
+
+		// Workflows
+		windsurfDir := cfg.Paths.Windsurf
+		if windsurfDir == "" {
+			windsurfDir = ".windsurf"
+		}
+		for _, wf := range []string{"default-mode.md", "multiagency.md", "orchestrator.md"} {
+			p := filepath.Join(dir, windsurfDir, "workflows", wf)
 			if _, err := os.Stat(p); err == nil {
-				pass(fmt.Sprintf("repo rules (%s)", t.DisplayName))
+				pass(fmt.Sprintf("workflow/%s", wf))
 			} else {
-				fail(fmt.Sprintf("repo rules (%s)", t.DisplayName), "missing")
+				fail(fmt.Sprintf("workflow/%s", wf), "missing")
 			}
 		}
-	}
 
-	// 6. Workflows
-	windsurfDir := cfg.Paths.Windsurf
-	if windsurfDir == "" {
-		windsurfDir = ".windsurf"
-	}
-	for _, wf := range []string{"default-mode.md", "multiagency.md", "orchestrator.md"} {
-		p := filepath.Join(dir, windsurfDir, "workflows", wf)
-		if _, err := os.Stat(p); err == nil {
-			pass(fmt.Sprintf("workflow/%s", wf))
+		// Orchestrator
+		statePath := filepath.Join(dir, windsurfDir, "orchestrator", "session_state.yaml")
+		if _, err := os.Stat(statePath); err == nil {
+			pass("session_state.yaml")
 		} else {
-			fail(fmt.Sprintf("workflow/%s", wf), "missing")
+			fail("session_state.yaml", "missing")
 		}
 	}
 
-	// 7. Orchestrator
-	statePath := filepath.Join(dir, windsurfDir, "orchestrator", "session_state.yaml")
-	if _, err := os.Stat(statePath); err == nil {
-		pass("session_state.yaml")
-	} else {
-		fail("session_state.yaml", "missing")
-	}
-
-	// 8. Decisions directory
+	// 6. Decisions directory
 	decisionsDir := filepath.Join(dir, "decisions")
 	if _, err := os.Stat(decisionsDir); err == nil {
 		pass("decisions/")
@@ -862,7 +1637,7 @@ func cmdDoctor() {
 		warn("decisions/", "not found — run `aiops sync` to create")
 	}
 
-	// 9. Multiagency module
+	// 7. Multiagency module (optional — `aiops run` uses the embedded runtime)
 	multiagencyDir := cfg.Paths.Multiagency
 	if multiagencyDir == "" {
 		multiagencyDir = "multiagency"
@@ -870,17 +1645,35 @@ func cmdDoctor() {
 	multiagencyMod := filepath.Join(dir, multiagencyDir, "go.mod")
 	if _, err := os.Stat(multiagencyMod); err == nil {
 		pass("multiagency/go.mod")
+	} else if cfg.Multiagency.SkipModule {
+		pass("multiagency module skipped (multiagency.skip_module)")
 	} else {
 		warn("multiagency/go.mod", "not found")
 	}
 
-	// 10. Version check
+	// 8. Version check
 	if cfg.Version != config.Version {
 		warn("version", fmt.Sprintf("config says %s, binary is %s — run `aiops update`", cfg.Version, config.Version))
 	} else {
 		pass(fmt.Sprintf("version (%s)", config.Version))
 	}
 
+	// 9. Template overrides, stale when the built-in moved on since eject
+	overrides, err := renderer.Overrides(dir, cfg)
+	if err != nil {
+		warn("template overrides", err.Error())
+	}
+	for _, o := range overrides {
+		label := fmt.Sprintf("template %s (%s override)", o.Path, o.Layer)
+		if o.Renamed != "" {
+			fail(label, fmt.Sprintf("the built-in is now %s, so both render the same file — rename the override to %s", o.Renamed, o.Renamed))
+		} else if o.Stale {
+			warn(label, fmt.Sprintf("the built-in changed since it was ejected with aiops %s — re-eject it with `aiops template eject %s --force` and re-apply your edits", o.Ejected.Version, o.Path))
+		} else {
+			pass(label)
+		}
+	}
+
 	// Summary
 	fmt.Printf("\n%d passed, %d warnings, %d failed\n", passed, warned, failed)
 	if failed > 0 {
@@ -893,8 +1686,882 @@ func cmdDoctor() {
 	}
 }
 
+// --- schema command ---
+
+func cmdSchema() {
+	dir := getDir()
+	kind := "config"
+	if args := positionalArgs(2); len(args) > 0 {
+		kind = args[0]
+	}
+
+	var schema []byte
+	var err error
+	var rel string
+	switch kind {
+	case "config":
+		schema, err = config.JSONSchema()
+		rel = config.SchemaPath
+	case "spec":
+		schema, err = spec.JSONSchema()
+		rel, _ = filepath.Rel(dir, filepath.Join(multiagencyDir(dir), "specs", renderer.SpecSchemaFile))
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: aiops schema [config|spec] [--write]")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating schema: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !hasFlag("--write") {
+		fmt.Print(string(schema))
+		return
+	}
+
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, schema, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote %s\n", rel)
+	fmt.Println("\nSet `editor.schema_headers: true` in .aiops.yaml and run `aiops sync` to reference it from generated YAML.")
+}
+
+// --- run command ---
+
+func cmdRun() {
+	dir := getDir()
+	args := positionalArgs(2)
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: aiops run <spec> --task \"...\" [--provider anthropic] [--format sarif] [-o file]")
+		os.Exit(1)
+	}
+	task := flagValue("--task", "-t")
+	if task == "" {
+		fmt.Fprintln(os.Stderr, "Error: --task is required")
+		os.Exit(1)
+	}
+	format := flagValue("--format", "-f")
+	if format == "" {
+		format = report.FormatJSON
+	}
+
+	specPath := resolveSpec(dir, args[0])
+	workflowSpec, err := spec.LoadFromFile(specPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+		os.Exit(1)
+	}
+	workflowSpec.LLM.Override(flagValue("--provider"), flagValue("--model"))
+
+	switch format {
+	case report.FormatJSON:
+	case report.FormatSARIF, report.FormatJUnit, report.FormatMarkdown:
+		if workflowSpec.Report == nil {
+			fmt.Fprintf(os.Stderr, "Error: --format %s needs a report: section in %s\n", format, specPath)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --format %q (use one of: %s)\n", format, strings.Join(report.Formats, ", "))
+		os.Exit(1)
+	}
+
+	executor, err := newExecutor(dir, workflowSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := executor.Execute(ctx, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var output []byte
+	if format == report.FormatJSON {
+		output, err = json.MarshalIndent(result, "", "  ")
+		output = append(output, '\n')
+	} else {
+		output, err = report.Render(format, workflowSpec, result, config.Version)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting result: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outFile := flagValue("-o", "--output"); outFile != "" {
+		if err := os.WriteFile(outFile, output, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Wrote %s\n", outFile)
+	} else {
+		os.Stdout.Write(output)
+	}
+
+	if result.Status == pipeline.StatusCancelled {
+		fmt.Fprintf(os.Stderr, "⚠ Pipeline %s\n", result.Error)
+		os.Exit(1)
+	}
+}
+
+// newExecutor creates a pipeline executor for a spec with the run flags
+// applied: progress on stderr, --verbose, --no-cache and --no-memory.
+func newExecutor(dir string, workflowSpec *spec.WorkflowSpec) (*pipeline.Executor, error) {
+	// Keep cached responses next to the specs, where the generated .gitignore covers them
+	if workflowSpec.LLM.Cache == nil {
+		workflowSpec.LLM.Cache = &spec.Cache{}
+	}
+	if workflowSpec.LLM.Cache.Dir == "" {
+		workflowSpec.LLM.Cache.Dir = filepath.Join(multiagencyDir(dir), llm.DefaultCacheDir)
+	}
+
+	client, err := pipeline.NewClient(&workflowSpec.LLM, hasFlag("--no-cache"))
+	if err != nil {
+		return nil, err
+	}
+
+	executor := pipeline.NewExecutor(workflowSpec, client)
+	executor.SetOutput(os.Stderr)
+	executor.SetVerbose(hasFlag("--verbose", "-v"))
+	if !hasFlag("--no-memory") {
+		executor.SetMemory(memory.NewStore(memoryDir(dir)))
+	}
+	return executor, nil
+}
+
+// interruptContext returns a context cancelled by the first Ctrl-C, so a
+// pipeline stops and keeps partial results; a second Ctrl-C exits.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// --- review command ---
+
+// maxReviewDiff caps the diff passed to the pipeline; larger diffs are cut
+// and the touched file list still names every file.
+const maxReviewDiff = 200 << 10
+
+func cmdReview() {
+	dir := getDir()
+
+	diffArgs := []string{"diff", "HEAD"}
+	scope := "uncommitted changes"
+	untracked := true
+	switch {
+	case hasFlag("--staged"):
+		diffArgs = []string{"diff", "--cached"}
+		scope = "staged changes"
+		untracked = false
+	case flagValue("--range") != "":
+		rng := flagValue("--range")
+		diffArgs = []string{"diff", rng}
+		scope = "commits " + rng
+		untracked = false
+	}
+
+	failOn := flagValue("--fail-on")
+	if failOn == "" {
+		failOn = "high"
+	}
+	blockingRank, ok := map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "none": -1}[failOn]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown --fail-on %q (use critical, high, medium, low or none)\n", failOn)
+		os.Exit(1)
+	}
+
+	diff, err := git(dir, diffArgs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	names, err := git(dir, append(diffArgs, "--name-only")...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	files := strings.Fields(names)
+	if untracked {
+		newDiff, newFiles, err := untrackedDiff(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		diff += newDiff
+		files = append(files, newFiles...)
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Printf("No %s to review.\n", scope)
+		return
+	}
+	diff = truncateDiff(diff, maxReviewDiff)
+
+	specName := flagValue("--spec")
+	if specName == "" {
+		specName = "code_review"
+	}
+	workflowSpec, err := spec.LoadFromFile(resolveSpec(dir, specName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+		os.Exit(1)
+	}
+	if workflowSpec.Report == nil {
+		fmt.Fprintf(os.Stderr, "Error: spec %q needs a report: section to list findings\n", workflowSpec.Name)
+		os.Exit(1)
+	}
+	workflowSpec.LLM.Override(flagValue("--provider"), flagValue("--model"))
+
+	executor, err := newExecutor(dir, workflowSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	executor.SetInputs(map[string]interface{}{
+		"diff":          diff,
+		"touched_files": files,
+	})
+
+	fmt.Fprintf(os.Stderr, "aiops review — %s (%d files)\n\n", scope, len(files))
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	task := fmt.Sprintf("Review the %s in this repository. The unified diff and the touched files are provided as context; "+
+		"report locations as file:line in the new version of each file.", scope)
+	result, err := executor.Execute(ctx, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rep, err := report.Build(workflowSpec, result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	blocking := printReview(rep, blockingRank)
+
+	if result.Status == pipeline.StatusCancelled {
+		fmt.Fprintf(os.Stderr, "⚠ Pipeline %s\n", result.Error)
+		os.Exit(1)
+	}
+	if blocking > 0 || rep.Failed() {
+		fmt.Printf("\n✗ Review blocked: %d blocking findings, %d failed gates\n", blocking, countFailed(rep.Gates))
+		os.Exit(1)
+	}
+	fmt.Println("\n✓ No blocking findings")
+}
+
+// untrackedDiff returns new-file diffs for the untracked files git does not
+// ignore, which `git diff HEAD` leaves out, and their names.
+func untrackedDiff(dir string) (string, []string, error) {
+	out, err := git(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return "", nil, err
+	}
+	var diff strings.Builder
+	var files []string
+	for _, name := range strings.Split(out, "\x00") {
+		if name == "" {
+			continue
+		}
+		// --no-index exits 1 when the files differ, which they always do here
+		cmd := exec.Command("git", "diff", "--no-index", "--", os.DevNull, name)
+		cmd.Dir = dir
+		patch, err := cmd.Output()
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return "", nil, fmt.Errorf("git diff %s: %v", name, err)
+		}
+		diff.Write(patch)
+		files = append(files, name)
+	}
+	return diff.String(), files, nil
+}
+
+// truncateDiff cuts a diff longer than limit at the last file or hunk header
+// that fits, or failing that at a line end, so no hunk is cut short and no
+// UTF-8 sequence is split.
+func truncateDiff(diff string, limit int) string {
+	if len(diff) <= limit {
+		return diff
+	}
+	head := diff[:limit]
+	cut := max(strings.LastIndex(head, "\ndiff --git "), strings.LastIndex(head, "\n@@ "))
+	if cut < 0 {
+		cut = strings.LastIndex(head, "\n")
+	}
+	return diff[:cut+1] + "... diff truncated ...\n"
+}
+
+// printReview prints findings grouped by severity and the gate results, and
+// returns how many findings are at or above the blocking rank.
+func printReview(rep *report.Report, blockingRank int) int {
+	title := rep.Title
+	if rep.Verdict != "" {
+		title += " — " + rep.Verdict
+	}
+	fmt.Println(title)
+	if rep.Summary != "" {
+		fmt.Printf("\n%s\n", rep.Summary)
+	}
+
+	order := []string{"critical", "high", "medium", "low", "info", "unrated"}
+	groups := map[string][]report.Finding{}
+	for _, f := range rep.Findings {
+		severity := strings.ToLower(f.Severity)
+		if !slices.Contains(order, severity) {
+			severity = "unrated"
+		}
+		groups[severity] = append(groups[severity], f)
+	}
+
+	blocking := 0
+	for i, severity := range order {
+		findings := groups[severity]
+		if len(findings) == 0 {
+			continue
+		}
+		isBlocking := blockingRank >= 0 && i <= blockingRank
+		fmt.Printf("\n%s (%d)\n", severity, len(findings))
+		for _, f := range findings {
+			mark := "⚠"
+			if isBlocking {
+				mark = "✗"
+				blocking++
+			}
+			where := f.Location
+			if f.HasLocation() {
+				where = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			if where != "" {
+				where += " — "
+			}
+			fmt.Printf("  %s [%s] %s%s (%s)\n", mark, f.Rule, where, f.Message, f.Agent)
+		}
+	}
+	if len(rep.Findings) == 0 {
+		fmt.Println("\nNo findings.")
+	}
+
+	if len(rep.Gates) > 0 {
+		fmt.Println("\nGates:")
+		for _, g := range rep.Gates {
+			if g.Passed {
+				fmt.Printf("  ✓ %s\n", g.Name)
+			} else {
+				fmt.Printf("  ✗ %s: %s\n", g.Name, g.Reason)
+			}
+		}
+	}
+	return blocking
+}
+
+func countFailed(gates []report.GateResult) int {
+	n := 0
+	for _, g := range gates {
+		if !g.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// git runs a git command in dir and returns its stdout.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// --- specs command ---
+
+func cmdSpecs() {
+	if len(os.Args) < 3 || os.Args[2] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: aiops specs validate [spec...]")
+		os.Exit(1)
+	}
+	dir := getDir()
+
+	var paths []string
+	for _, arg := range positionalArgs(3) {
+		paths = append(paths, resolveSpec(dir, arg))
+	}
+	if len(paths) == 0 {
+		specsDir := filepath.Join(multiagencyDir(dir), "specs")
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(specsDir, pattern))
+			paths = append(paths, matches...)
+		}
+		if len(paths) == 0 {
+			fmt.Printf("No specs found in %s\n", specsDir)
+			return
+		}
+	}
+
+	failed := 0
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		workflowSpec, err := spec.LoadFromFile(path)
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", rel, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  ✓ %s — %s (%d agents)\n", rel, workflowSpec.Name, len(workflowSpec.Agents))
+	}
+
+	fmt.Printf("\n%d valid, %d invalid\n", len(paths)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// --- template command ---
+
+func cmdTemplate() {
+	if len(os.Args) < 3 || (os.Args[2] != "eject" && os.Args[2] != "list") {
+		fmt.Fprintln(os.Stderr, "Usage: aiops template eject <path> [--force] | aiops template list")
+		os.Exit(1)
+	}
+	dir := getDir()
+
+	if os.Args[2] == "list" {
+		cfg, err := config.Load(dir)
+		if err != nil {
+			fmt.Println("✗ Not initialized. Run `aiops init` first.")
+			os.Exit(1)
+		}
+		overrides, err := renderer.Overrides(dir, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(overrides) == 0 {
+			fmt.Printf("No template overrides. Eject a built-in with `aiops template eject <path>`.\n")
+			return
+		}
+		for _, o := range overrides {
+			fmt.Printf("  %-8s %s — %s\n", o.Layer, o.Path, overrideState(o))
+		}
+		return
+	}
+
+	args := positionalArgs(3)
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: aiops template eject <path> [--force]")
+		fmt.Fprintln(os.Stderr, "\nBuilt-in templates:")
+		builtins, _ := updater.ListTemplateFiles()
+		for _, path := range builtins {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
+		}
+		os.Exit(1)
+	}
+	for _, path := range args {
+		rel, err := renderer.Eject(dir, path, hasFlag("--force"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  ✗ %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("  ✓ %s\n", rel)
+	}
+	fmt.Println("\nEdit the copy and run `aiops update` to render it. Delete it to go back to the built-in.")
+}
+
+// overrideState describes how an override relates to the built-in template.
+func overrideState(o renderer.Override) string {
+	switch {
+	case o.Renamed != "":
+		return fmt.Sprintf("clashes with the built-in %s: rename it to match", o.Renamed)
+	case !o.Builtin:
+		return "adds a template"
+	case o.Stale:
+		return fmt.Sprintf("stale: the built-in changed since it was ejected (aiops %s)", o.Ejected.Version)
+	case o.Ejected == nil:
+		return "overrides the built-in (not ejected, cannot tell if stale)"
+	}
+	return "overrides the built-in"
+}
+
+// --- pack command ---
+
+func cmdPack() {
+	usage := "Usage: aiops pack add <dir|tarball> | aiops pack list | aiops pack remove <name>"
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	dir := getDir()
+	cfg, err := config.Load(dir)
+	if err != nil {
+		fmt.Println("✗ Not initialized. Run `aiops init` first.")
+		os.Exit(1)
+	}
+	args := positionalArgs(3)
+
+	switch os.Args[2] {
+	case "add":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: aiops pack add <dir|tarball>")
+			os.Exit(1)
+		}
+		m, err := pack.Install(dir, args[0], checkPackConditions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding pack: %v\n", err)
+			os.Exit(1)
+		}
+
+		entry := config.Pack{Name: m.Name, Version: m.Version, Source: args[0]}
+		if i := slices.IndexFunc(cfg.Packs, func(p config.Pack) bool { return p.Name == m.Name }); i >= 0 {
+			cfg.Packs[i] = entry
+		} else {
+			cfg.Packs = append(cfg.Packs, entry)
+		}
+		if err := config.Save(dir, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Added pack %s%s to %s\n", m.Name, versionSuffix(m.Version), filepath.Join(pack.Dir, m.Name))
+		if ok, _ := renderer.PackApplies(cfg, m); !ok {
+			fmt.Println("  ! Its conditions do not hold for this project, so nothing will be rendered from it.")
+		}
+		fmt.Println("\nRun `aiops update` to render it.")
+
+	case "list":
+		if len(cfg.Packs) == 0 {
+			fmt.Println("No packs installed. Add one with `aiops pack add <dir|tarball>`.")
+			return
+		}
+		for _, p := range cfg.Packs {
+			m, err := pack.Load(pack.Path(dir, p.Name))
+			if err != nil {
+				fmt.Printf("  ✗ %s — %v\n", p.Name, err)
+				continue
+			}
+			state := "applies"
+			if ok, err := renderer.PackApplies(cfg, m); err != nil {
+				state = err.Error()
+			} else if !ok {
+				state = "conditions not met: " + strings.Join(m.When, ", ")
+			}
+			fmt.Printf("  %s%s — %s", m.Name, versionSuffix(m.Version), state)
+			if m.Description != "" {
+				fmt.Printf("\n      %s", m.Description)
+			}
+			fmt.Println()
+		}
+
+	case "remove":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: aiops pack remove <name>")
+			os.Exit(1)
+		}
+		name := args[0]
+		i := slices.IndexFunc(cfg.Packs, func(p config.Pack) bool { return p.Name == name })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "Error: no pack %s installed\n", name)
+			os.Exit(1)
+		}
+		if err := pack.Remove(dir, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing pack: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Packs = slices.Delete(cfg.Packs, i, i+1)
+		if err := config.Save(dir, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Removed pack %s\n", name)
+		fmt.Println("\nRun `aiops update` to delete the files it generated. Seeded specs and skills are kept.")
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// checkPackConditions rejects packs with conditions that can never hold.
+func checkPackConditions(m *pack.Manifest) error {
+	if err := renderer.CheckConditions(m.When); err != nil {
+		return err
+	}
+	for _, rule := range m.Files {
+		if err := renderer.CheckConditions(rule.When); err != nil {
+			return fmt.Errorf("files %s: %w", rule.Path, err)
+		}
+	}
+	return nil
+}
+
+// versionSuffix formats an optional version for display.
+func versionSuffix(version string) string {
+	if version == "" {
+		return ""
+	}
+	return " " + version
+}
+
+// --- memory command ---
+
+func cmdMemory() {
+	sub := ""
+	if len(os.Args) >= 3 {
+		sub = os.Args[2]
+	}
+	args := positionalArgs(3)
+	if (sub != "list" && sub != "prune" && sub != "clear") || len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: aiops memory list|prune|clear [spec] [--agent id] [--older-than 720h] [--keep n]")
+		os.Exit(1)
+	}
+	dir := getDir()
+	store := memory.NewStore(memoryDir(dir))
+
+	// Entries are keyed by workflow name, so a spec argument is loaded to find its key
+	var scopes []memory.Scope
+	agentID := flagValue("--agent")
+	switch {
+	case len(args) == 1:
+		workflowSpec, err := spec.LoadFromFile(resolveSpec(dir, args[0]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+			os.Exit(1)
+		}
+		key := memory.Key(workflowSpec.Name)
+		if agentID != "" {
+			if workflowSpec.GetAgentByID(agentID) == nil {
+				fmt.Fprintf(os.Stderr, "Error: agent '%s' not found in %s\n", agentID, workflowSpec.Name)
+				os.Exit(1)
+			}
+			scopes = append(scopes, memory.Scope{Spec: key, Agent: agentID})
+		} else {
+			scopes, err = store.Scopes(key)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case agentID != "":
+		fmt.Fprintln(os.Stderr, "Error: --agent needs a spec")
+		os.Exit(1)
+	case sub == "clear":
+		fmt.Fprintln(os.Stderr, "Error: name the spec to clear; memory of other workflows is left alone")
+		os.Exit(1)
+	default:
+		var err error
+		if scopes, err = store.Scopes(""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch sub {
+	case "list":
+		if len(scopes) == 0 {
+			fmt.Println("No agent memory saved.")
+			return
+		}
+		for _, scope := range scopes {
+			entries, err := store.Load(scope)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s/%s (%d entries)\n", scope.Spec, scope.Agent, len(entries))
+			for _, e := range entries {
+				value, _ := json.Marshal(e.Value)
+				text := string(value)
+				if len(text) > 80 {
+					text = text[:80] + "..."
+				}
+				fmt.Printf("  %s  %-20s seen %dx, last %s  %s\n", e.ID, e.Field, e.Count, e.LastSeen.Format("2006-01-02"), text)
+			}
+		}
+
+	case "prune":
+		var olderThan time.Duration
+		if v := flagValue("--older-than"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --older-than: %v\n", err)
+				os.Exit(1)
+			}
+			olderThan = d
+		}
+		keep := 0
+		if v := flagValue("--keep"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid --keep %q\n", v)
+				os.Exit(1)
+			}
+			keep = n
+		}
+		if olderThan <= 0 && keep <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: pass --older-than, --keep or both")
+			os.Exit(1)
+		}
+		total := 0
+		for _, scope := range scopes {
+			removed, err := store.Prune(scope, olderThan, keep)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if removed > 0 {
+				fmt.Printf("  %s/%s: removed %d\n", scope.Spec, scope.Agent, removed)
+			}
+			total += removed
+		}
+		fmt.Printf("✓ Pruned %d entries\n", total)
+
+	case "clear":
+		total := 0
+		for _, scope := range scopes {
+			removed, err := store.Clear(scope)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			total += removed
+		}
+		fmt.Printf("✓ Cleared %d entries\n", total)
+	}
+}
+
+// memoryDir returns where agent memory is kept for a project.
+func memoryDir(dir string) string {
+	return filepath.Join(dir, ".aiops", "memory")
+}
+
+// multiagencyDir returns the project's multiagency directory, falling back to
+// the default when the project has no .aiops.yaml.
+func multiagencyDir(dir string) string {
+	name := config.DefaultPaths().Multiagency
+	if cfg, err := config.Load(dir); err == nil && cfg.Paths.Multiagency != "" {
+		name = cfg.Paths.Multiagency
+	}
+	return filepath.Join(dir, name)
+}
+
+// resolveSpec accepts a spec path, or a spec name looked up in the project's
+// multiagency/specs directory (the .yaml extension is optional).
+func resolveSpec(dir, name string) string {
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	path := filepath.Join(multiagencyDir(dir), "specs", name)
+	if filepath.Ext(name) == "" {
+		path += ".yaml"
+	}
+	return path
+}
+
 // --- helpers ---
 
+// hasFlag reports whether any of the given flags was passed on the command line.
+func hasFlag(names ...string) bool {
+	for _, arg := range os.Args[2:] {
+		for _, name := range names {
+			if arg == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flagValue returns the value of the first given flag, written as
+// `--flag value` or `--flag=value`, or "" when it was not passed.
+func flagValue(names ...string) string {
+	args := os.Args[2:]
+	for i, arg := range args {
+		for _, name := range names {
+			if arg == name && i+1 < len(args) {
+				return args[i+1]
+			}
+			if strings.HasPrefix(arg, name+"=") {
+				return strings.TrimPrefix(arg, name+"=")
+			}
+		}
+	}
+	return ""
+}
+
+// flagValues returns every value of the given flags, which may be repeated
+// and hold comma-separated lists.
+func flagValues(names ...string) []string {
+	args := os.Args[2:]
+	var values []string
+	for i, arg := range args {
+		for _, name := range names {
+			value := ""
+			switch {
+			case arg == name && i+1 < len(args):
+				value = args[i+1]
+			case strings.HasPrefix(arg, name+"="):
+				value = strings.TrimPrefix(arg, name+"=")
+			default:
+				continue
+			}
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// positionalArgs returns the arguments from index start on that are neither
+// flags nor flag values.
+func positionalArgs(start int) []string {
+	var out []string
+	for i := start; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && i+1 < len(os.Args) && valueFlags[arg] {
+				i++
+			}
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// valueFlags are the flags that take a value, so positionalArgs can skip it.
+var valueFlags = map[string]bool{
+	"--dir": true, "--task": true, "-t": true, "--provider": true, "--model": true,
+	"--format": true, "-f": true, "-o": true, "--output": true,
+	"--agent": true, "--older-than": true, "--keep": true,
+	"--range": true, "--spec": true, "--fail-on": true,
+	"--only": true, "--exclude": true, "--strategy": true,
+}
+
 func printDetected(stack *config.DetectedStack) {
 	fmt.Println("Detected:")
 
@@ -0,0 +1,219 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/voltic-software/aiops/internal/config"
+	"github.com/voltic-software/aiops/internal/renderer"
+)
+
+// Dir holds one snapshot per operation, relative to the project directory.
+const Dir = ".aiops/backups"
+
+// recordFile describes the snapshot, next to the saved files.
+const recordFile = "backup.json"
+
+// filesDir holds the saved contents inside a snapshot.
+const filesDir = "files"
+
+// ignoreFile keeps the snapshots out of git; it is written with the first one.
+const ignoreFile = ".gitignore"
+
+// Record describes a snapshot taken before an operation wrote to the project.
+type Record struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"` // init, sync or update
+	Version   string    `json:"version"`   // aiops version that ran the operation
+	Created   time.Time `json:"created"`
+	Files     []Entry   `json:"files"`
+}
+
+// Entry is one path the operation was about to write or remove.
+type Entry struct {
+	Path    string `json:"path"`    // relative to the project directory
+	Existed bool   `json:"existed"` // false: the operation created it, so rollback removes it
+}
+
+// Create saves the current contents of paths before operation overwrites
+// them, together with the config, the manifest and the merge bases, so a
+// rollback restores aiops' own state as well. Paths outside the project
+// are not saved. Callers Prune once the snapshot they restore from, if
+// any, is no longer needed.
+func Create(projectDir, operation string, paths []string) (*Record, error) {
+	all := []string{config.ConfigFileName, renderer.ManifestPath}
+	for _, p := range paths {
+		all = append(all, p, filepath.Join(renderer.BaseDir, p))
+	}
+
+	now := time.Now()
+	id := now.Format("20060102-150405")
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(projectDir, Dir, id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405"), i)
+	}
+	dir := filepath.Join(projectDir, Dir, id)
+
+	// Snapshots are local undo history, not something to commit
+	ignore := filepath.Join(projectDir, Dir, ignoreFile)
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := writeFile(ignore, []byte("*\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	rec := &Record{ID: id, Operation: operation, Version: config.Version, Created: now}
+	seen := map[string]bool{}
+	for _, p := range all {
+		p = filepath.Clean(p)
+		if seen[p] || !filepath.IsLocal(p) {
+			continue
+		}
+		seen[p] = true
+
+		content, err := os.ReadFile(filepath.Join(projectDir, p))
+		if os.IsNotExist(err) {
+			rec.Files = append(rec.Files, Entry{Path: p})
+			continue
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("backing up %s: %w", p, err)
+		}
+		if err := writeFile(filepath.Join(dir, filesDir, p), content); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		rec.Files = append(rec.Files, Entry{Path: p, Existed: true})
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(filepath.Join(dir, recordFile), append(data, '\n')); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return rec, nil
+}
+
+// List returns the project's snapshots, newest first.
+func List(projectDir string) ([]Record, error) {
+	entries, err := os.ReadDir(filepath.Join(projectDir, Dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading backups: %w", err)
+	}
+
+	var records []Record
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		rec, err := Load(projectDir, e.Name())
+		if err != nil {
+			continue // not a snapshot, or one interrupted while being written
+		}
+		records = append(records, *rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Created.After(records[j].Created) })
+	return records, nil
+}
+
+// Load reads the record of the snapshot with the given ID.
+func Load(projectDir, id string) (*Record, error) {
+	if !filepath.IsLocal(id) {
+		return nil, fmt.Errorf("invalid backup id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(projectDir, Dir, id, recordFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no backup %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading backup %s: %w", id, err)
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parsing backup %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+// Restore puts the project back into the state the snapshot saved: saved
+// files are written back and files the operation created are removed. It
+// returns the restored and the removed paths, also the ones it got to
+// before failing.
+func Restore(projectDir string, rec *Record) ([]string, []string, error) {
+	var files []renderer.File
+	var created []string
+	for _, e := range rec.Files {
+		if !e.Existed {
+			created = append(created, e.Path)
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(projectDir, Dir, rec.ID, filesDir, e.Path))
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading backup of %s: %w", e.Path, err)
+		}
+		files = append(files, renderer.File{Path: e.Path, Content: content})
+	}
+
+	restored, err := renderer.WriteFiles(projectDir, files)
+	if err != nil {
+		return restored, nil, err
+	}
+
+	var removed []string
+	for _, p := range created {
+		err := os.Remove(filepath.Join(projectDir, p))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return restored, removed, fmt.Errorf("removing %s: %w", p, err)
+		}
+		removed = append(removed, p)
+
+		// Drop directories the operation created and that are empty again
+		for dir := filepath.Dir(p); dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(filepath.Join(projectDir, dir)) != nil {
+				break
+			}
+		}
+	}
+	return restored, removed, nil
+}
+
+// Prune deletes all but the newest keep snapshots.
+func Prune(projectDir string, keep int) error {
+	records, err := List(projectDir)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records[min(keep, len(records)):] {
+		if err := os.RemoveAll(filepath.Join(projectDir, Dir, rec.ID)); err != nil {
+			return fmt.Errorf("removing backup %s: %w", rec.ID, err)
+		}
+	}
+	return nil
+}
+
+// writeFile creates parent directories and writes content.
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
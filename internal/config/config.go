@@ -15,10 +15,22 @@ var Version = "dev"
 
 // ProjectConfig is the root configuration generated by `aiops init`.
 type ProjectConfig struct {
-	Version  string        `yaml:"version"`
-	Project  Project       `yaml:"project"`
-	Paths    Paths         `yaml:"paths"`
-	Detected DetectedStack `yaml:"detected"`
+	Version   string        `yaml:"version" jsonschema:"required"`
+	Project   Project       `yaml:"project" jsonschema:"required"`
+	Paths     Paths         `yaml:"paths"`
+	Detected  DetectedStack `yaml:"detected"`
+	Editor    Editor        `yaml:"editor,omitempty"`
+	Managed   Managed       `yaml:"managed,omitempty"`
+	Backups   Backups       `yaml:"backups,omitempty"`
+	Templates Templates     `yaml:"templates,omitempty"`
+	Packs     []Pack        `yaml:"packs,omitempty"`
+	Rules     Rules         `yaml:"rules,omitempty"`
+
+	// Vars are team facts (on-call rules, forbidden dirs, review policy)
+	// passed to templates as .Vars, e.g. {{.Vars.oncall}}.
+	Vars map[string]any `yaml:"vars,omitempty" jsonschema:"description=Team facts passed to templates as .Vars"`
+
+	Multiagency Multiagency `yaml:"multiagency,omitempty"`
 }
 
 // Maturity levels for project lifecycle.
@@ -30,8 +42,8 @@ const (
 
 // Project holds user-confirmed project metadata.
 type Project struct {
-	Name     string `yaml:"name"`
-	Maturity string `yaml:"maturity"` // bootstrap, active, mature
+	Name     string `yaml:"name" jsonschema:"required"`
+	Maturity string `yaml:"maturity" jsonschema:"enum=bootstrap|active|mature"` // bootstrap, active, mature
 }
 
 // Paths holds the output locations for generated artifacts.
@@ -41,6 +53,75 @@ type Paths struct {
 	Targets     []string `yaml:"targets,omitempty"` // detected IDE targets: windsurf, cursor, continue, copilot
 }
 
+// Editor holds settings that improve the hand-editing experience of generated YAML.
+type Editor struct {
+	// SchemaHeaders adds `# yaml-language-server: $schema=` headers to
+	// .aiops.yaml and generated workflow specs.
+	SchemaHeaders bool `yaml:"schema_headers,omitempty"`
+}
+
+// Managed holds settings for files aiops shares with hand-written content.
+// In those it only owns the block between <!-- aiops:begin --> and
+// <!-- aiops:end -->; the Copilot instructions are always shared this way.
+type Managed struct {
+	// Files are more project-relative markdown or mdc artifacts to render as
+	// a managed block instead of a whole file.
+	Files []string `yaml:"files,omitempty"`
+
+	// Insert is where the block goes in an existing file without one:
+	// bottom (default), top (after any front matter), or after:<heading>,
+	// e.g. "after:## AI assistants".
+	Insert string `yaml:"insert,omitempty" jsonschema:"description=Where to insert the block in existing files: bottom (default), top, or after:<heading line>"`
+}
+
+// Templates holds template override settings. A file in .aiops/templates/
+// replaces the built-in template at the same path; `aiops template eject`
+// copies one out to start from.
+type Templates struct {
+	// Org is a directory of overrides shared across an organization's
+	// repositories, applied beneath the project's own. Relative paths are
+	// resolved against the project directory and ~ against the home directory.
+	Org string `yaml:"org,omitempty" jsonschema:"description=Directory of org-level template overrides, applied beneath .aiops/templates/"`
+}
+
+// Pack is a template pack installed with `aiops pack add`. Its files live
+// in .aiops/packs/<name>/ and are rendered along with the built-ins.
+type Pack struct {
+	Name    string `yaml:"name" jsonschema:"required"`
+	Version string `yaml:"version,omitempty"`
+	Source  string `yaml:"source,omitempty"` // directory or archive it was added from
+}
+
+// DefaultBackupKeep is the number of backups kept when backups.keep is unset.
+const DefaultBackupKeep = 10
+
+// Backups holds settings for the snapshots init, sync and update save
+// under .aiops/backups/ before overwriting anything.
+type Backups struct {
+	// Keep caps the number of snapshots; older ones are deleted. 0 keeps
+	// DefaultBackupKeep, a negative number turns backups off.
+	Keep int `yaml:"keep,omitempty" jsonschema:"description=Number of backups to keep (default 10; negative disables backups)"`
+}
+
+// Limit returns the number of backups to keep, 0 when they are off.
+func (b Backups) Limit() int {
+	switch {
+	case b.Keep < 0:
+		return 0
+	case b.Keep == 0:
+		return DefaultBackupKeep
+	}
+	return b.Keep
+}
+
+// Multiagency holds settings for multiagency workflow specs.
+type Multiagency struct {
+	// SkipModule stops aiops from generating the standalone multiagency Go
+	// module (go.mod, cmd/, internal/). Specs are still written and run with
+	// `aiops run`, which needs no Go toolchain.
+	SkipModule bool `yaml:"skip_module,omitempty"`
+}
+
 // DetectedStack holds the auto-detected technology stack.
 type DetectedStack struct {
 	Languages  []Language      `yaml:"languages"`
@@ -128,6 +209,9 @@ func Save(dir string, cfg *ProjectConfig) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	header := "# Generated by aiops init — do not edit manually unless you know what you're doing.\n# Run `aiops status` to check for updates.\n\n"
+	if cfg.Editor.SchemaHeaders {
+		header = SchemaHeader(SchemaPath) + header
+	}
 	return os.WriteFile(path, []byte(header+string(data)), 0644)
 }
 
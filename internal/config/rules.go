@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rules configures the generated repo rules and workflows.
+type Rules struct {
+	// Modules turns named sections off or sets their parameters.
+	Modules RuleModules `yaml:"modules,omitempty"`
+}
+
+// RuleModules maps a module name from KnownModules to its settings:
+// `enabled: false` drops the section, the other keys set its parameters.
+//
+//	escalation:
+//	  budget: 3
+//	intent_guardrail:
+//	  enabled: false
+type RuleModules map[string]map[string]any
+
+// ModuleSchema describes a named section of the rules and workflows.
+type ModuleSchema struct {
+	Name        string
+	Description string
+	Params      []ModuleParam
+}
+
+// ModuleParam is a parameter of a module.
+type ModuleParam struct {
+	Name        string
+	Kind        string // "integer" or "duration"
+	Description string
+}
+
+// KnownModules are the sections rules.modules can configure.
+var KnownModules = []ModuleSchema{
+	{Name: "human_override", Description: "The @directive human override"},
+	{Name: "intent_guardrail", Description: "Intent snapshot and scope drift prevention"},
+	{Name: "task_routing", Description: "Three-tier task routing and focused expert personas"},
+	{Name: "escalation", Description: "Escalation budget and transparency", Params: []ModuleParam{
+		{Name: "budget", Kind: "integer", Description: "Escalations per session (default: 4 bootstrap, 2 active, 1 mature)"},
+	}},
+	{Name: "session_coordination", Description: "Cross-session locks and the build failure rule", Params: []ModuleParam{
+		{Name: "lock_ttl", Kind: "duration", Description: "Default lock duration, e.g. 2h (default 2h)"},
+	}},
+	{Name: "decisions_memory", Description: "Reading and respecting decisions/"},
+}
+
+// Enabled reports whether a module is on; all are unless turned off.
+func (m RuleModules) Enabled(name string) bool {
+	enabled, ok := m[name]["enabled"].(bool)
+	return !ok || enabled
+}
+
+// Param returns a parameter set for a module.
+func (m RuleModules) Param(module, name string) (any, bool) {
+	v, ok := m[module][name]
+	return v, ok
+}
+
+// Validate checks the modules and their parameters against KnownModules.
+func (m RuleModules) Validate() error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		i := slices.IndexFunc(KnownModules, func(s ModuleSchema) bool { return s.Name == name })
+		if i < 0 {
+			var known []string
+			for _, s := range KnownModules {
+				known = append(known, s.Name)
+			}
+			return fmt.Errorf("rules.modules: unknown module %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		schema := KnownModules[i]
+		for key, value := range m[name] {
+			if key == "enabled" {
+				if _, ok := value.(bool); !ok {
+					return fmt.Errorf("rules.modules.%s.enabled: must be true or false", name)
+				}
+				continue
+			}
+			j := slices.IndexFunc(schema.Params, func(p ModuleParam) bool { return p.Name == key })
+			if j < 0 {
+				return fmt.Errorf("rules.modules.%s: unknown parameter %q", name, key)
+			}
+			if err := checkParam(schema.Params[j], value); err != nil {
+				return fmt.Errorf("rules.modules.%s.%s: %w", name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkParam checks a parameter value against its kind.
+func checkParam(p ModuleParam, value any) error {
+	switch p.Kind {
+	case "integer":
+		if n, ok := value.(int); !ok || n < 0 {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+	case "duration":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a duration such as 2h or 30m")
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("must be a duration such as 2h or 30m")
+		}
+	}
+	return nil
+}
+
+// JSONSchema describes the known modules, so editors complete and check them.
+func (RuleModules) JSONSchema() map[string]interface{} {
+	props := map[string]interface{}{}
+	for _, s := range KnownModules {
+		params := map[string]interface{}{
+			"enabled": map[string]interface{}{"type": "boolean", "description": "Set to false to drop the section"},
+		}
+		for _, p := range s.Params {
+			param := map[string]interface{}{"type": "integer", "minimum": 0, "description": p.Description}
+			if p.Kind == "duration" {
+				param = map[string]interface{}{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`, "description": p.Description}
+			}
+			params[p.Name] = param
+		}
+		props[s.Name] = map[string]interface{}{
+			"type":                 "object",
+			"description":          s.Description,
+			"properties":           params,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"github.com/voltic-software/aiops/internal/runtime/jsonschema"
+)
+
+// SchemaPath is where the .aiops.yaml JSON Schema is written, relative to the project dir.
+const SchemaPath = ".aiops/aiops.schema.json"
+
+// JSONSchema returns the JSON Schema for .aiops.yaml, generated from ProjectConfig.
+func JSONSchema() ([]byte, error) {
+	s := jsonschema.Generate(ProjectConfig{}, "https://github.com/voltic-software/aiops/aiops.schema.json", "aiops project config")
+	return jsonschema.Marshal(s)
+}
+
+// SchemaHeader returns the yaml-language-server modeline pointing at a schema file.
+func SchemaHeader(schemaPath string) string {
+	return "# yaml-language-server: $schema=" + schemaPath + "\n"
+}
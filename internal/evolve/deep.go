@@ -0,0 +1,232 @@
+package evolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Proposal sources in a merged report.
+const (
+	SourceRules = "rules" // counted from the directive log by DetectPatterns
+	SourceAudit = "audit" // proposed by the evolution_audit spec
+)
+
+// Audit is the output of the evolution_audit spec's discovery and proposal agents.
+type Audit struct {
+	Summary        string          `json:"summary"`
+	Changes        []Change        `json:"changes_found"`
+	StaleKnowledge []StaleArtifact `json:"stale_knowledge"`
+	Proposals      []AuditProposal `json:"proposals"`
+	ProposalNotes  string          `json:"proposal_summary"`
+}
+
+// Change is a meaningful codebase change found by the discovery agent.
+type Change struct {
+	Category     string `json:"category"`
+	Description  string `json:"description"`
+	Evidence     string `json:"evidence"`
+	Confidence   string `json:"confidence"`
+	Significance string `json:"significance"`
+}
+
+// StaleArtifact is a knowledge artifact the discovery agent found out of date.
+type StaleArtifact struct {
+	Artifact     string `json:"artifact"`
+	WhatIsStale  string `json:"what_is_stale"`
+	CurrentState string `json:"current_state"`
+	Severity     string `json:"severity"`
+}
+
+// AuditProposal is an update proposed by the proposal agent.
+type AuditProposal struct {
+	ID              string `json:"id"`
+	Artifact        string `json:"artifact"`
+	Priority        string `json:"priority"`
+	Action          string `json:"action"`
+	Description     string `json:"description"`
+	ProposedContent string `json:"proposed_content"`
+	Justification   string `json:"justification"`
+}
+
+// Proposal is one entry of a merged report, from either source.
+type Proposal struct {
+	ID              string
+	Source          string
+	Priority        string
+	Artifact        string
+	Description     string
+	ProposedContent string
+	Justification   string
+}
+
+// ParseAudit reads the discovery and proposal agents' outputs. Either may be
+// nil when the pipeline stopped early.
+func ParseAudit(discovery, proposal map[string]interface{}) (*Audit, error) {
+	audit := &Audit{}
+	if discovery != nil {
+		if err := remarshal(discovery, audit); err != nil {
+			return nil, fmt.Errorf("discovery output: %w", err)
+		}
+	}
+	if proposal != nil {
+		var p struct {
+			Proposals []AuditProposal `json:"proposals"`
+			Summary   string          `json:"summary"`
+		}
+		if err := remarshal(proposal, &p); err != nil {
+			return nil, fmt.Errorf("proposal output: %w", err)
+		}
+		audit.Proposals = p.Proposals
+		audit.ProposalNotes = p.Summary
+	}
+	return audit, nil
+}
+
+func remarshal(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// MergeProposals combines rule-based patterns and audit proposals into one
+// list ordered by priority. Patterns overridden three or more times are high
+// priority, the rest medium.
+func MergeProposals(patterns []Pattern, audit *Audit) []Proposal {
+	var merged []Proposal
+	for i, p := range patterns {
+		priority := "medium"
+		if p.Count >= 3 {
+			priority = "high"
+		}
+		merged = append(merged, Proposal{
+			ID:            fmt.Sprintf("R-%03d", i+1),
+			Source:        SourceRules,
+			Priority:      priority,
+			Artifact:      fmt.Sprintf("rule `%s`", p.Rule),
+			Description:   fmt.Sprintf("`%s` overridden %d times", p.Rule, p.Count),
+			Justification: p.Proposal,
+		})
+	}
+	if audit != nil {
+		for i, p := range audit.Proposals {
+			id := p.ID
+			if id == "" {
+				id = fmt.Sprintf("P-%03d", i+1)
+			}
+			merged = append(merged, Proposal{
+				ID:              id,
+				Source:          SourceAudit,
+				Priority:        strings.ToLower(p.Priority),
+				Artifact:        p.Artifact,
+				Description:     p.Description,
+				ProposedContent: p.ProposedContent,
+				Justification:   p.Justification,
+			})
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return priorityRank(merged[i].Priority) < priorityRank(merged[j].Priority)
+	})
+	return merged
+}
+
+func priorityRank(priority string) int {
+	switch priority {
+	case "critical":
+		return 0
+	case "high":
+		return 1
+	case "medium":
+		return 2
+	case "low":
+		return 3
+	}
+	return 4
+}
+
+// GenerateDeepReport produces the report of `aiops evolve --deep`: the audit's
+// findings followed by the merged proposals.
+func GenerateDeepReport(patterns []Pattern, totalDirectives int, audit *Audit) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Evolution Analysis Report (deep)\n\n")
+	sb.WriteString(fmt.Sprintf("Total directives logged: %d\n", totalDirectives))
+	sb.WriteString(fmt.Sprintf("Patterns detected: %d\n", len(patterns)))
+	sb.WriteString(fmt.Sprintf("Changes found by audit: %d\n\n", len(audit.Changes)))
+
+	if audit.Summary != "" || audit.ProposalNotes != "" {
+		sb.WriteString("## Summary\n\n")
+		for _, text := range []string{audit.Summary, audit.ProposalNotes} {
+			if text != "" {
+				sb.WriteString(text + "\n\n")
+			}
+		}
+	}
+
+	if len(audit.Changes) > 0 {
+		sb.WriteString("## Changes Found\n\n")
+		for _, c := range audit.Changes {
+			sb.WriteString(fmt.Sprintf("- **%s** (%s, %s confidence): %s\n", c.Category, c.Significance, c.Confidence, c.Description))
+			if c.Evidence != "" {
+				sb.WriteString(fmt.Sprintf("  Evidence: %s\n", c.Evidence))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(audit.StaleKnowledge) > 0 {
+		sb.WriteString("## Stale Knowledge\n\n")
+		for _, s := range audit.StaleKnowledge {
+			sb.WriteString(fmt.Sprintf("- `%s` [%s]: %s\n", s.Artifact, s.Severity, s.WhatIsStale))
+			if s.CurrentState != "" {
+				sb.WriteString(fmt.Sprintf("  Now: %s\n", s.CurrentState))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	proposals := MergeProposals(patterns, audit)
+	if len(proposals) == 0 {
+		sb.WriteString("No proposals. Default rules and knowledge artifacts appear up to date.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("## Proposals\n\n")
+	for _, p := range proposals {
+		sb.WriteString(fmt.Sprintf("### %s [%s] %s\n\n", p.ID, p.Priority, p.Artifact))
+		sb.WriteString(fmt.Sprintf("Source: %s\n\n", p.Source))
+		if p.Description != "" {
+			sb.WriteString(p.Description + "\n\n")
+		}
+		if p.ProposedContent != "" {
+			sb.WriteString("**Proposed content:**\n\n```\n" + strings.TrimRight(p.ProposedContent, "\n") + "\n```\n\n")
+		}
+		if p.Justification != "" {
+			sb.WriteString(fmt.Sprintf("**Why:** %s\n\n", p.Justification))
+		}
+		sb.WriteString("---\n\n")
+	}
+
+	if len(patterns) > 0 {
+		sb.WriteString("## Directive Overrides\n\n")
+		for _, p := range patterns {
+			sb.WriteString(fmt.Sprintf("**`%s`**\n", p.Rule))
+			for _, d := range p.Directives {
+				sb.WriteString(fmt.Sprintf("- [%s] Session `%s`: \"%s\"\n", d.Timestamp, d.Session, d.Directive))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString("## Next Steps\n\n")
+	sb.WriteString("1. Review each proposal; none are applied automatically\n")
+	sb.WriteString("2. If approved, update the repo rules or knowledge artifacts accordingly\n")
+	sb.WriteString("3. Clear the `directive_log` in `session_state.yaml`\n")
+
+	return sb.String()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -11,16 +12,42 @@ import (
 
 // DirectiveEntry represents a single @directive override logged in session_state.yaml.
 type DirectiveEntry struct {
-	Session        string `yaml:"session"`
-	Directive      string `yaml:"directive"`
-	Reason         string `yaml:"reason"`
-	Timestamp      string `yaml:"timestamp"`
-	RuleOverridden string `yaml:"rule_overridden"`
+	Session        string `yaml:"session" json:"session"`
+	Directive      string `yaml:"directive" json:"directive"`
+	Reason         string `yaml:"reason" json:"reason,omitempty"`
+	Timestamp      string `yaml:"timestamp" json:"timestamp,omitempty"`
+	RuleOverridden string `yaml:"rule_overridden" json:"rule_overridden,omitempty"`
+}
+
+// CompletedSession is a finished workstream recorded in session_state.yaml.
+type CompletedSession struct {
+	ID           string   `yaml:"id" json:"id"`
+	Focus        string   `yaml:"focus" json:"focus,omitempty"`
+	CompletedAt  string   `yaml:"completed_at" json:"completed_at,omitempty"`
+	Outcome      string   `yaml:"outcome" json:"outcome,omitempty"`
+	FilesChanged []string `yaml:"files_changed" json:"files_changed,omitempty"`
 }
 
 // SessionState is a minimal parse of session_state.yaml for reading directive_log.
 type SessionState struct {
-	DirectiveLog []DirectiveEntry `yaml:"directive_log"`
+	DirectiveLog      []DirectiveEntry   `yaml:"directive_log"`
+	CompletedSessions []CompletedSession `yaml:"completed_sessions"`
+}
+
+// LoadState reads the orchestrator's session_state.yaml.
+func LoadState(projectDir, windsurfDir string) (*SessionState, error) {
+	statePath := filepath.Join(projectDir, windsurfDir, "orchestrator", "session_state.yaml")
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("no session_state.yaml found: %w", err)
+	}
+
+	var state SessionState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing session_state.yaml: %w", err)
+	}
+	return &state, nil
 }
 
 // Pattern represents a detected pattern from directive logs.
@@ -33,25 +60,23 @@ type Pattern struct {
 
 // AnalyzeDirectives reads the directive log and detects patterns.
 func AnalyzeDirectives(projectDir, windsurfDir string) ([]Pattern, error) {
-	statePath := filepath.Join(projectDir, windsurfDir, "orchestrator", "session_state.yaml")
-
-	data, err := os.ReadFile(statePath)
+	state, err := LoadState(projectDir, windsurfDir)
 	if err != nil {
-		return nil, fmt.Errorf("no session_state.yaml found: %w", err)
-	}
-
-	var state SessionState
-	if err := yaml.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("parsing session_state.yaml: %w", err)
+		return nil, err
 	}
+	return DetectPatterns(state.DirectiveLog), nil
+}
 
-	if len(state.DirectiveLog) == 0 {
-		return nil, nil
+// DetectPatterns groups directives by the rule they overrode and proposes a
+// change for every rule overridden at least twice.
+func DetectPatterns(log []DirectiveEntry) []Pattern {
+	if len(log) == 0 {
+		return nil
 	}
 
 	// Group by rule_overridden
 	ruleCount := map[string][]DirectiveEntry{}
-	for _, d := range state.DirectiveLog {
+	for _, d := range log {
 		rule := d.RuleOverridden
 		if rule == "" {
 			rule = "unknown"
@@ -74,7 +99,13 @@ func AnalyzeDirectives(projectDir, windsurfDir string) ([]Pattern, error) {
 		patterns = append(patterns, p)
 	}
 
-	return patterns, nil
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].Rule < patterns[j].Rule
+	})
+	return patterns
 }
 
 // GenerateReport produces a human-readable evolution report.
@@ -0,0 +1,260 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir holds the installed packs, one directory per pack, relative to the
+// project directory.
+const Dir = ".aiops/packs"
+
+// ManifestFile describes a pack, at its root.
+const ManifestFile = "aiops-pack.yaml"
+
+// Kinds are the directories a pack may contain:
+//   - rules: fragments appended to every target's repo rules
+//   - workflows: rendered into every target's workflows directory
+//   - skills: <name>/SKILL.md scaffolds for targets with skills
+//   - specs: multiagency specs
+var Kinds = []string{"rules", "workflows", "skills", "specs"}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Manifest is a pack's aiops-pack.yaml.
+type Manifest struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version,omitempty"`
+	Description string `yaml:"description,omitempty"`
+
+	// When lists the conditions under which the pack applies at all. Each is
+	// a template flag such as HasGo or IsMature, or the name of a detected
+	// language, framework or pattern such as event-sourcing; "!" negates.
+	// All must hold.
+	When []string `yaml:"when,omitempty"`
+
+	// Files adds conditions to the files matching a path pattern, e.g.
+	// workflows/eventsrc-*.md.tmpl.
+	Files []FileRule `yaml:"files,omitempty"`
+}
+
+// FileRule limits the pack files matching Path to projects meeting When.
+type FileRule struct {
+	Path string   `yaml:"path"`
+	When []string `yaml:"when"`
+}
+
+// Conditions returns the conditions of a pack file: those of the pack and
+// of every rule matching the file.
+func (m *Manifest) Conditions(file string) []string {
+	conditions := append([]string(nil), m.When...)
+	for _, rule := range m.Files {
+		if ok, _ := path.Match(rule.Path, file); ok || rule.Path == file {
+			conditions = append(conditions, rule.When...)
+		}
+	}
+	return conditions
+}
+
+// Path returns the directory of an installed pack.
+func Path(projectDir, name string) string {
+	return filepath.Join(projectDir, Dir, name)
+}
+
+// Load reads and checks the manifest of the pack in dir.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", ManifestFile, err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ManifestFile, err)
+	}
+	if !validName.MatchString(m.Name) {
+		return nil, fmt.Errorf("%s: name %q must be lowercase letters, digits, '.', '-' or '_'", ManifestFile, m.Name)
+	}
+	for _, rule := range m.Files {
+		if _, err := path.Match(rule.Path, ""); err != nil {
+			return nil, fmt.Errorf("%s: files: bad pattern %q", ManifestFile, rule.Path)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && !isKind(e.Name()) && !strings.HasPrefix(e.Name(), ".") {
+			return nil, fmt.Errorf("unknown pack directory %s/ (expected %s)", e.Name(), strings.Join(Kinds, ", "))
+		}
+	}
+	return &m, nil
+}
+
+// Install copies the pack at source, a directory or a .tar, .tar.gz or
+// .tgz archive, into the project, replacing an installed pack of the same
+// name. The manifest may sit at the root or in a single top-level directory.
+// check, if not nil, can reject the pack before anything is replaced.
+func Install(projectDir, source string, check func(*Manifest) error) (*Manifest, error) {
+	if err := os.MkdirAll(filepath.Join(projectDir, Dir), 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(filepath.Join(projectDir, Dir), ".add-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		err = copyDir(source, staging)
+	} else {
+		err = extract(source, staging)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading pack %s: %w", source, err)
+	}
+
+	root := staging
+	if _, err := os.Stat(filepath.Join(root, ManifestFile)); os.IsNotExist(err) {
+		entries, _ := os.ReadDir(root)
+		if len(entries) == 1 && entries[0].IsDir() {
+			root = filepath.Join(root, entries[0].Name())
+		}
+	}
+	m, err := Load(root)
+	if err != nil {
+		return nil, err
+	}
+	if check != nil {
+		if err := check(m); err != nil {
+			return nil, err
+		}
+	}
+
+	dest := Path(projectDir, m.Name)
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(root, dest); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Remove deletes an installed pack.
+func Remove(projectDir, name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid pack name %q", name)
+	}
+	return os.RemoveAll(Path(projectDir, name))
+}
+
+// isKind reports whether name is one of Kinds.
+func isKind(name string) bool {
+	for _, k := range Kinds {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// copyDir copies the regular files below src into dst.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), content, 0644)
+	})
+}
+
+// extract unpacks the regular files of a tar archive, gzipped or not,
+// into dst. Entries that would land outside dst are refused.
+func extract(archive, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(archive, ".gz") || strings.HasSuffix(archive, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else if !strings.HasSuffix(archive, ".tar") {
+		return fmt.Errorf("not a directory or a .tar, .tar.gz or .tgz archive")
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %s is outside the pack", hdr.Name)
+		}
+		out := filepath.Join(dst, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+				return err
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(out, content, 0644); err != nil {
+				return err
+			}
+		}
+	}
+}
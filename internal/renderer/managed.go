@@ -0,0 +1,163 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/config"
+	"github.com/voltic-software/aiops/internal/target"
+)
+
+// Managed-block markers. In files aiops shares with hand-written content it
+// only owns the lines between them.
+const (
+	ManagedBegin = "<!-- aiops:begin -->"
+	ManagedEnd   = "<!-- aiops:end -->"
+)
+
+// Where a managed block goes in an existing file that has none yet.
+const (
+	InsertBottom = "bottom"
+	InsertTop    = "top"
+	insertAfter  = "after:" // followed by the heading line to insert after
+)
+
+// managedPaths returns the project-relative files rendered as a managed
+// block: the rules of targets that share them with hand-written content,
+// plus those listed under managed.files.
+func managedPaths(cfg *config.ProjectConfig, targets []target.Target) (map[string]bool, error) {
+	paths := map[string]bool{}
+	for _, t := range targets {
+		if t.ManagedRules && t.RepoRulesPath != "" {
+			paths[filepath.Clean(t.RepoRulesPath)] = true
+		}
+	}
+	for _, p := range cfg.Managed.Files {
+		switch filepath.Ext(p) {
+		case ".md", ".mdc":
+			paths[filepath.Clean(p)] = true
+		default:
+			return nil, fmt.Errorf("managed.files: %s is not a markdown or mdc file", p)
+		}
+	}
+
+	insert := cfg.Managed.Insert
+	if insert != "" && insert != InsertBottom && insert != InsertTop && !strings.HasPrefix(insert, insertAfter) {
+		return nil, fmt.Errorf("managed.insert: %q is not top, bottom or after:<heading>", insert)
+	}
+	return paths, nil
+}
+
+// applyManaged turns the rendered files that share a file with hand-written
+// content into that file with only its managed block replaced.
+func applyManaged(projectDir string, cfg *config.ProjectConfig, files []File, managed map[string]bool) error {
+	for i := range files {
+		f := &files[i]
+		if !managed[f.Path] {
+			continue
+		}
+		existing, err := os.ReadFile(filepath.Join(projectDir, f.Path))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading %s: %w", f.Path, err)
+		}
+		f.Content = composeManaged(existing, f.Content, cfg.Managed.Insert)
+		f.Ownership = OwnerManaged
+	}
+	return nil
+}
+
+// composeManaged returns existing with its managed block replaced by the
+// rendered content, or with a new block inserted at insert. A file that
+// does not exist yet gets the rendered front matter and the block.
+func composeManaged(existing, rendered []byte, insert string) []byte {
+	frontMatter, body := splitFrontMatter(rendered)
+	block := ManagedBegin + "\n" + strings.TrimRight(string(body), "\n") + "\n" + ManagedEnd + "\n"
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return append(frontMatter, block...)
+	}
+
+	lines := strings.SplitAfter(string(existing), "\n")
+	if begin, end, ok := findBlock(lines); ok {
+		return []byte(strings.Join(lines[:begin], "") + block + strings.Join(lines[end+1:], ""))
+	}
+
+	switch {
+	case insert == InsertTop:
+		fm, rest := splitFrontMatter(existing)
+		return []byte(string(fm) + block + "\n" + strings.TrimLeft(string(rest), "\n"))
+	case strings.HasPrefix(insert, insertAfter):
+		heading := strings.TrimSpace(strings.TrimPrefix(insert, insertAfter))
+		for i, line := range lines {
+			if strings.TrimSpace(line) == heading {
+				head := strings.Join(lines[:i+1], "")
+				if !strings.HasSuffix(head, "\n") {
+					head += "\n"
+				}
+				rest := strings.TrimLeft(strings.Join(lines[i+1:], ""), "\n")
+				return []byte(head + "\n" + block + "\n" + rest)
+			}
+		}
+	}
+	// bottom, and after:<heading> when the heading is missing
+	return []byte(strings.TrimRight(string(existing), "\n") + "\n\n" + block)
+}
+
+// ManagedBlock returns the content between the managed-block markers.
+func ManagedBlock(content []byte) ([]byte, bool) {
+	lines := strings.SplitAfter(string(content), "\n")
+	begin, end, ok := findBlock(lines)
+	if !ok {
+		return nil, false
+	}
+	return []byte(strings.Join(lines[begin+1:end], "")), true
+}
+
+// StripManaged removes the managed block and the blank line that separated
+// it from the hand-written content.
+func StripManaged(content []byte) ([]byte, bool) {
+	lines := strings.SplitAfter(string(content), "\n")
+	begin, end, ok := findBlock(lines)
+	if !ok {
+		return content, false
+	}
+	before, after := strings.Join(lines[:begin], ""), lines[end+1:]
+	if len(after) > 0 && strings.TrimSpace(after[0]) == "" {
+		after = after[1:]
+	}
+	if len(after) == 0 && strings.TrimSpace(before) != "" {
+		before = strings.TrimRight(before, "\n") + "\n"
+	}
+	return []byte(before + strings.Join(after, "")), true
+}
+
+// findBlock returns the line indexes of the begin and end markers.
+func findBlock(lines []string) (int, int, bool) {
+	begin := -1
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case begin < 0 && line == ManagedBegin:
+			begin = i
+		case begin >= 0 && line == ManagedEnd:
+			return begin, i, true
+		}
+	}
+	return 0, 0, false
+}
+
+// splitFrontMatter splits a leading --- delimited front matter block, as
+// used by mdc rules and workflows, from the rest of the content.
+func splitFrontMatter(content []byte) ([]byte, []byte) {
+	if !bytes.HasPrefix(content, []byte("---\n")) {
+		return nil, content
+	}
+	end := bytes.Index(content[4:], []byte("\n---\n"))
+	if end < 0 {
+		return nil, content
+	}
+	end += 4 + len("\n---\n")
+	return content[:end:end], content[end:]
+}
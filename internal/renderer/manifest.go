@@ -0,0 +1,210 @@
+package renderer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/voltic-software/aiops/internal/config"
+)
+
+// ManifestPath is where RenderAll records the files it wrote, relative to
+// the project directory.
+const ManifestPath = ".aiops/manifest.json"
+
+// BaseDir keeps the last rendered content of aiops-owned files, relative
+// to the project directory. It is the base of the three-way merge that
+// lets aiops update keep local edits.
+const BaseDir = ".aiops/base"
+
+// Ownership policies of rendered files.
+const (
+	// OwnerAiops files are rewritten on every render and removed on uninstall.
+	OwnerAiops = "aiops-owned"
+	// OwnerSeedOnce files are written when missing and then left to the team;
+	// uninstall only removes them while they are unchanged.
+	OwnerSeedOnce = "seed-once"
+	// OwnerUser files are created once for the team to fill in and are never
+	// rewritten or removed.
+	OwnerUser = "user-owned"
+	// OwnerManaged files are shared with hand-written content: aiops rewrites
+	// and on uninstall removes only its managed block.
+	OwnerManaged = "managed-block"
+)
+
+// ManifestEntry describes one file aiops wrote.
+type ManifestEntry struct {
+	Path      string `json:"path"` // relative to the project directory
+	Template  string `json:"template,omitempty"`
+	Hash      string `json:"hash"` // SHA-256 of the rendered content, or of the managed block
+	Target    string `json:"target"`
+	Ownership string `json:"ownership"`
+	Base      string `json:"base,omitempty"` // copy of the rendered content under BaseDir
+}
+
+// Manifest lists the files aiops has written to a project.
+type Manifest struct {
+	Version string          `json:"version"` // aiops version that last wrote it
+	Files   []ManifestEntry `json:"files"`
+}
+
+// LoadManifest reads the project's manifest. A project without one, e.g.
+// installed by an older aiops, gets an empty manifest.
+func LoadManifest(projectDir string) (*Manifest, error) {
+	path := filepath.Join(projectDir, ManifestPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ManifestPath, err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest, sorted by path.
+func (m *Manifest) Save(projectDir string) error {
+	m.Version = config.Version
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(projectDir, ManifestPath), append(data, '\n'))
+}
+
+// Lookup returns the entry for a project-relative path, or nil.
+func (m *Manifest) Lookup(path string) *ManifestEntry {
+	for i := range m.Files {
+		if m.Files[i].Path == path {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// Record adds or updates the entries of files that were just rendered and
+// saves the rendered content of aiops-owned files as their merge base.
+func (m *Manifest) Record(projectDir string, files []File) error {
+	for _, f := range files {
+		entry := ManifestEntry{
+			Path:      f.Path,
+			Template:  f.Template,
+			Hash:      HashContent(f.Content),
+			Target:    f.Target,
+			Ownership: f.Ownership,
+		}
+		if block, ok := ManagedBlock(f.Content); ok && f.Ownership == OwnerManaged {
+			entry.Hash = HashContent(block)
+		}
+		// Files outside the project have no place under BaseDir
+		if entry.Rewritten() && filepath.IsLocal(f.Path) {
+			entry.Base = filepath.Join(BaseDir, f.Path)
+			if err := writeFile(filepath.Join(projectDir, entry.Base), f.Content); err != nil {
+				return err
+			}
+		}
+		if existing := m.Lookup(f.Path); existing != nil {
+			*existing = entry
+		} else {
+			m.Files = append(m.Files, entry)
+		}
+	}
+	return nil
+}
+
+// Replace records a full render. Entries of files the render skipped or no
+// longer produces are kept while the file still exists, so seeds the team
+// owns and obsolete artifacts stay tracked.
+func (m *Manifest) Replace(projectDir string, files []File) error {
+	rendered := make(map[string]bool, len(files))
+	for _, f := range files {
+		rendered[f.Path] = true
+	}
+	kept := m.Files[:0]
+	for _, entry := range m.Files {
+		if rendered[entry.Path] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(projectDir, entry.Path)); err == nil {
+			kept = append(kept, entry)
+		}
+	}
+	m.Files = kept
+	return m.Record(projectDir, files)
+}
+
+// Remove drops the entries of the given paths and their merge bases.
+func (m *Manifest) Remove(projectDir string, paths ...string) {
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+	kept := m.Files[:0]
+	for _, entry := range m.Files {
+		if !drop[entry.Path] {
+			kept = append(kept, entry)
+		} else if entry.Base != "" {
+			os.Remove(filepath.Join(projectDir, entry.Base))
+		}
+	}
+	m.Files = kept
+}
+
+// ReadBase returns the content aiops last rendered for the entry, or nil
+// when no base was kept.
+func (e *ManifestEntry) ReadBase(projectDir string) []byte {
+	if e.Base == "" {
+		return nil
+	}
+	content, err := os.ReadFile(filepath.Join(projectDir, e.Base))
+	if err != nil {
+		return nil
+	}
+	return content
+}
+
+// HashContent returns the hex SHA-256 of content as recorded in the manifest.
+func HashContent(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// States reported by ManifestEntry.Check.
+const (
+	StateOK       = "ok"
+	StateMissing  = "missing"
+	StateModified = "modified" // content differs from what aiops wrote
+)
+
+// Rewritten reports whether aiops rewrites the file, or its managed block,
+// on every render.
+func (e *ManifestEntry) Rewritten() bool {
+	return e.Ownership == OwnerAiops || e.Ownership == OwnerManaged
+}
+
+// Check compares the file on disk, or its managed block, with what aiops
+// wrote. A managed file whose block was removed counts as missing.
+func (e *ManifestEntry) Check(projectDir string) string {
+	content, err := os.ReadFile(filepath.Join(projectDir, e.Path))
+	if err != nil {
+		return StateMissing
+	}
+	if e.Ownership == OwnerManaged {
+		block, ok := ManagedBlock(content)
+		if !ok {
+			return StateMissing
+		}
+		content = block
+	}
+	if HashContent(content) != e.Hash {
+		return StateModified
+	}
+	return StateOK
+}
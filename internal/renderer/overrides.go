@@ -0,0 +1,261 @@
+package renderer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/config"
+)
+
+// OverridesDir holds the project's template overrides, relative to the
+// project directory. A file there replaces the built-in template at the
+// same path, e.g. .aiops/templates/repo_rules.md.tmpl.
+const OverridesDir = ".aiops/templates"
+
+// EjectRecord lists, in an overrides directory, the built-in version each
+// ejected template was copied from.
+const EjectRecord = ".ejected.json"
+
+// Ejected is the built-in template an override was copied from.
+type Ejected struct {
+	Hash    string `json:"hash"`    // SHA-256 of the built-in template
+	Version string `json:"version"` // aiops version that ejected it
+}
+
+// Override is a template file that replaces or adds to the built-ins.
+type Override struct {
+	Path    string   // template path, e.g. "repo_rules.md.tmpl"
+	Layer   string   // "project" or "org"
+	File    string   // the override file on disk
+	Builtin bool     // false when it adds a template the built-ins lack
+	Renamed string   // the built-in it renders over under another name, e.g. after x.md became x.md.tmpl
+	Ejected *Ejected // nil when it was not created with Eject
+	Stale   bool     // the built-in changed since it was ejected
+}
+
+// Templates returns the templates a project renders: the built-ins,
+// overlaid file by file with the org-level directory from templates.org
+// and then with the project's .aiops/templates.
+func Templates(projectDir string, cfg *config.ProjectConfig) fs.FS {
+	var layers []fs.FS
+	for _, l := range overrideLayers(projectDir, cfg) {
+		layers = append(layers, prefixFS{"templates", os.DirFS(l.dir)})
+	}
+	return overlayFS(append(layers, templateFS))
+}
+
+// Overrides lists the override files in effect, project ones first.
+func Overrides(projectDir string, cfg *config.ProjectConfig) ([]Override, error) {
+	var overrides []Override
+	for _, l := range overrideLayers(projectDir, cfg) {
+		ejected, err := loadEjected(l.dir)
+		if err != nil {
+			return nil, err
+		}
+		err = fs.WalkDir(os.DirFS(l.dir), ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == "." && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if d.IsDir() || p == EjectRecord {
+				return nil
+			}
+			o := Override{Path: p, Layer: l.name, File: filepath.Join(l.dir, filepath.FromSlash(p))}
+			builtin, err := templateFS.ReadFile("templates/" + p)
+			o.Builtin = err == nil
+			if e, ok := ejected[p]; ok {
+				o.Ejected = &e
+				o.Stale = o.Builtin && e.Hash != HashContent(builtin)
+			}
+			if !o.Builtin {
+				o.Renamed = renamedBuiltin(p)
+			}
+			overrides = append(overrides, o)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading template overrides in %s: %w", l.dir, err)
+		}
+	}
+	return overrides, nil
+}
+
+// Eject copies a built-in template into the project's overrides directory
+// for editing and records which version it was, so doctor can tell when
+// the built-in moves on. It returns the project-relative path written.
+func Eject(projectDir, templatePath string, force bool) (string, error) {
+	templatePath = strings.TrimPrefix(path.Clean(filepath.ToSlash(templatePath)), "templates/")
+	content, err := templateFS.ReadFile("templates/" + templatePath)
+	if err != nil {
+		return "", fmt.Errorf("no built-in template %s", templatePath)
+	}
+
+	rel := filepath.Join(OverridesDir, filepath.FromSlash(templatePath))
+	out := filepath.Join(projectDir, rel)
+	if _, err := os.Stat(out); err == nil && !force {
+		return "", fmt.Errorf("%s already exists", rel)
+	}
+	if err := writeFile(out, content); err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(projectDir, OverridesDir)
+	ejected, err := loadEjected(dir)
+	if err != nil {
+		return "", err
+	}
+	ejected[templatePath] = Ejected{Hash: HashContent(content), Version: config.Version}
+	data, err := json.MarshalIndent(ejected, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(filepath.Join(dir, EjectRecord), append(data, '\n')); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// renamedBuiltin returns the built-in template that renders to the same
+// file as p under the other name, with or without .tmpl, or "".
+func renamedBuiltin(p string) string {
+	other := p + ".tmpl"
+	if trimmed, ok := strings.CutSuffix(p, ".tmpl"); ok {
+		other = trimmed
+	}
+	if _, err := fs.Stat(templateFS, "templates/"+other); err != nil {
+		return ""
+	}
+	return other
+}
+
+// overrideLayer is a directory of template overrides.
+type overrideLayer struct {
+	name string
+	dir  string
+}
+
+// overrideLayers returns the override directories, highest priority first.
+func overrideLayers(projectDir string, cfg *config.ProjectConfig) []overrideLayer {
+	layers := []overrideLayer{{"project", filepath.Join(projectDir, OverridesDir)}}
+	if org := cfg.Templates.Org; org != "" {
+		if rest, ok := strings.CutPrefix(org, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				org = filepath.Join(home, rest)
+			}
+		}
+		if !filepath.IsAbs(org) {
+			org = filepath.Join(projectDir, org)
+		}
+		layers = append(layers, overrideLayer{"org", org})
+	}
+	return layers
+}
+
+// loadEjected reads the eject record of an overrides directory.
+func loadEjected(dir string) (map[string]Ejected, error) {
+	ejected := map[string]Ejected{}
+	data, err := os.ReadFile(filepath.Join(dir, EjectRecord))
+	if os.IsNotExist(err) {
+		return ejected, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", EjectRecord, err)
+	}
+	if err := json.Unmarshal(data, &ejected); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Join(dir, EjectRecord), err)
+	}
+	return ejected, nil
+}
+
+// overlayFS serves each file from the first layer that has it and merges
+// directory listings, so an override only needs the files it changes.
+type overlayFS []fs.FS
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, layer := range o {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	found := false
+	for _, layer := range o {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range layerEntries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// prefixFS mounts an overrides directory at prefix, where the built-ins
+// live in the embedded FS. The eject record is not a template.
+type prefixFS struct {
+	prefix string
+	fsys   fs.FS
+}
+
+func (p prefixFS) Open(name string) (fs.File, error) {
+	rest, ok := p.strip(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return p.fsys.Open(rest)
+}
+
+func (p prefixFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	rest, ok := p.strip(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, err := fs.ReadDir(p.fsys, rest)
+	if rest == "." {
+		entries = slices.DeleteFunc(entries, func(e fs.DirEntry) bool { return e.Name() == EjectRecord })
+	}
+	return entries, err
+}
+
+// strip maps a path below prefix to the wrapped FS.
+func (p prefixFS) strip(name string) (string, bool) {
+	if name == p.prefix {
+		return ".", true
+	}
+	rest, ok := strings.CutPrefix(name, p.prefix+"/")
+	if !ok || rest == EjectRecord {
+		return "", false
+	}
+	return rest, true
+}
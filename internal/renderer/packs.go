@@ -0,0 +1,199 @@
+package renderer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/voltic-software/aiops/internal/config"
+	"github.com/voltic-software/aiops/internal/pack"
+	"github.com/voltic-software/aiops/internal/target"
+)
+
+// renderPacks renders the installed packs into the set, after the
+// built-ins: rule fragments are appended to each target's repo rules,
+// workflows join the built-in ones, and skills and specs are seeded.
+func renderPacks(set *fileSet, cfg *config.ProjectConfig, targets []target.Target) error {
+	for _, p := range cfg.Packs {
+		dir := pack.Path(set.projectDir, p.Name)
+		m, err := pack.Load(dir)
+		if err != nil {
+			return fmt.Errorf("pack %s: %w", p.Name, err)
+		}
+		if err := renderPack(set, cfg, targets, m, os.DirFS(dir)); err != nil {
+			return fmt.Errorf("pack %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// renderPack renders the files of one pack whose conditions hold.
+func renderPack(set *fileSet, cfg *config.ProjectConfig, targets []target.Target, m *pack.Manifest, fsys fs.FS) error {
+	facts := conditionFacts(cfg)
+	for _, kind := range pack.Kinds {
+		err := fs.WalkDir(fsys, kind, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == kind && os.IsNotExist(err) {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ok, err := conditionsHold(m.Conditions(path), facts)
+			if err != nil || !ok {
+				return err
+			}
+			return renderPackFile(set, cfg, targets, m.Name, fsys, kind, path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPackFile renders a pack file of the given kind for every target
+// it applies to.
+func renderPackFile(set *fileSet, cfg *config.ProjectConfig, targets []target.Target, name string, fsys fs.FS, kind, path string) error {
+	source := "packs/" + name + "/" + path
+	rel := strings.TrimSuffix(strings.TrimPrefix(path, kind+"/"), ".tmpl")
+
+	if kind == "specs" {
+		set.target = "shared"
+		data := NewTemplateData(cfg)
+		data.TargetName = "shared"
+		multiagencyDir := cfg.Paths.Multiagency
+		if multiagencyDir == "" {
+			multiagencyDir = "multiagency"
+		}
+		out := filepath.Join(set.projectDir, multiagencyDir, "specs", rel)
+		if _, err := os.Stat(out); err == nil {
+			set.skip(out, source, OwnerSeedOnce)
+			return nil
+		}
+		output, err := renderFileContent(fsys, path, data)
+		if err != nil {
+			return err
+		}
+		if cfg.Editor.SchemaHeaders {
+			output = append([]byte(config.SchemaHeader(SpecSchemaFile)), output...)
+		}
+		return set.addNew(out, source, OwnerSeedOnce, output)
+	}
+
+	for _, t := range targets {
+		set.target = t.Name
+		data := targetData(cfg, t)
+		switch kind {
+		case "rules":
+			rules := set.lookup(t.Name, "repo_rules.md.tmpl")
+			if rules == nil {
+				continue
+			}
+			output, err := renderFileContent(fsys, path, data)
+			if err != nil {
+				return err
+			}
+			rules.Content = []byte(strings.TrimRight(string(rules.Content), "\n") + "\n\n" + strings.TrimSpace(string(output)) + "\n")
+		case "workflows":
+			dir := t.ResolveWorkflowsDir(set.projectDir)
+			if dir == "" {
+				continue
+			}
+			output, err := renderFileContent(fsys, path, data)
+			if err != nil {
+				return err
+			}
+			if err := set.addNew(filepath.Join(dir, rel), source, OwnerAiops, output); err != nil {
+				return err
+			}
+		case "skills":
+			dir := t.ResolveSkillsDir(set.projectDir)
+			if dir == "" {
+				continue
+			}
+			out := filepath.Join(dir, rel)
+			if _, err := os.Stat(out); err == nil {
+				set.skip(out, source, OwnerSeedOnce)
+				continue
+			}
+			output, err := renderFileContent(fsys, path, data)
+			if err != nil {
+				return err
+			}
+			if err := set.addNew(out, source, OwnerSeedOnce, output); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckConditions reports conditions that can never be checked: flags
+// (capitalized terms) that are not boolean fields of TemplateData.
+func CheckConditions(conditions []string) error {
+	facts := conditionFacts(&config.ProjectConfig{})
+	_, err := conditionsHold(conditions, facts)
+	return err
+}
+
+// PackApplies reports whether a pack's own conditions hold for a project.
+func PackApplies(cfg *config.ProjectConfig, m *pack.Manifest) (bool, error) {
+	return conditionsHold(m.When, conditionFacts(cfg))
+}
+
+// conditionFacts returns the values of the condition terms for a project:
+// every boolean field of TemplateData by name, and true for the names of
+// the detected languages, frameworks and patterns.
+func conditionFacts(cfg *config.ProjectConfig) map[string]bool {
+	facts := map[string]bool{}
+	data := reflect.ValueOf(*NewTemplateData(cfg))
+	for i := 0; i < data.NumField(); i++ {
+		if data.Field(i).Kind() == reflect.Bool {
+			facts[data.Type().Field(i).Name] = data.Field(i).Bool()
+		}
+	}
+	for _, lang := range cfg.Detected.Languages {
+		facts[lang.Name] = true
+	}
+	for _, fw := range cfg.Detected.Frameworks {
+		facts[fw.Name] = true
+	}
+	for _, p := range cfg.Detected.Patterns {
+		facts[p] = true
+	}
+	return facts
+}
+
+// conditionsHold reports whether all conditions hold. A condition is a
+// flag or detected name, negated by a leading "!". Unknown flags are an
+// error; unknown names are simply not detected.
+func conditionsHold(conditions []string, facts map[string]bool) (bool, error) {
+	holds := true
+	for _, c := range conditions {
+		term, negated := strings.CutPrefix(strings.TrimSpace(c), "!")
+		value, known := facts[term]
+		if !known && term != "" && unicode.IsUpper(rune(term[0])) {
+			return false, fmt.Errorf("unknown condition %q", c)
+		}
+		if value == negated {
+			holds = false
+		}
+	}
+	return holds, nil
+}
+
+// targetData returns the template data for rendering a target.
+func targetData(cfg *config.ProjectConfig, t target.Target) *TemplateData {
+	data := NewTemplateData(cfg)
+	data.TargetName = t.Name
+	data.TargetDisplay = t.DisplayName
+	data.OrchestrDir = t.OrchestrDir
+	return data
+}
@@ -3,6 +3,7 @@ package renderer
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -11,12 +12,22 @@ import (
 	"text/template"
 
 	"github.com/voltic-software/aiops/internal/config"
+	"github.com/voltic-software/aiops/internal/runtime"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
 	"github.com/voltic-software/aiops/internal/target"
 )
 
 //go:embed all:templates
 var templateFS embed.FS
 
+// SpecSchemaFile is the workflow spec JSON Schema referenced by spec headers.
+// It lives next to the specs and is rendered along with them.
+const SpecSchemaFile = "workflow.schema.json"
+
+// moduleFiles are the template paths that only belong to the standalone
+// multiagency Go module; the runtime sources are added from internal/runtime.
+var moduleFiles = []string{"go.mod.tmpl", "cmd/"}
+
 // TemplateData is the data passed to all templates.
 type TemplateData struct {
 	Project        config.Project
@@ -48,6 +59,36 @@ type TemplateData struct {
 	IsBootstrap    bool
 	IsActive       bool
 	IsMature       bool
+	Vars           map[string]any // vars: from .aiops.yaml
+
+	modules config.RuleModules // rules.modules, read through Module and Param
+}
+
+// Module reports whether a rules module is enabled, for sections such as
+// {{if .Module "intent_guardrail"}}. See config.KnownModules.
+func (d *TemplateData) Module(name string) bool {
+	return d.modules.Enabled(name)
+}
+
+// Param returns a rules module parameter as set in .aiops.yaml, or its
+// default: {{.Param "escalation" "budget"}}.
+func (d *TemplateData) Param(module, name string) any {
+	if v, ok := d.modules.Param(module, name); ok {
+		return v
+	}
+	switch module + "." + name {
+	case "escalation.budget":
+		switch {
+		case d.IsBootstrap:
+			return 4
+		case d.IsMature:
+			return 1
+		}
+		return 2
+	case "session_coordination.lock_ttl":
+		return "2h"
+	}
+	return nil
 }
 
 // NewTemplateData builds template data from a project config.
@@ -126,6 +167,9 @@ func NewTemplateData(cfg *config.ProjectConfig) *TemplateData {
 	td.IsActive = cfg.Project.Maturity == config.MaturityActive
 	td.IsMature = cfg.Project.Maturity == config.MaturityMature
 
+	td.Vars = cfg.Vars
+	td.modules = cfg.Rules.Modules
+
 	return td
 }
 
@@ -134,28 +178,203 @@ func GetTemplateFS() embed.FS {
 	return templateFS
 }
 
-// RenderAll renders all templates to the project directory for all detected targets.
+// File is a rendered artifact held in memory.
+type File struct {
+	Path      string // relative to the project directory
+	Content   []byte
+	Template  string // source in the template FS, or the runtime source path
+	Target    string // target name, or "shared" for target-independent files
+	Ownership string // OwnerAiops, OwnerSeedOnce, OwnerUser or OwnerManaged
+}
+
+// Skip is a file, or directory of seeds, a render left out because the
+// project already has it.
+type Skip struct {
+	Path      string // relative to the project directory
+	Template  string
+	Target    string
+	Ownership string
+}
+
+// Output is a render held in memory: the files to write and the skip
+// decisions made against the project.
+type Output struct {
+	Files   []File
+	Skipped []Skip
+}
+
+// RenderAll renders all templates to the project directory for all
+// detected targets and records what it wrote in the manifest. When writing
+// fails partway, the files already written are returned with the error and
+// recorded.
 func RenderAll(projectDir string, cfg *config.ProjectConfig) ([]string, error) {
+	out, err := RenderFiles(projectDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	written, writeErr := WriteFiles(projectDir, out.Files)
+
+	manifest, err := LoadManifest(projectDir)
+	if err != nil {
+		return written, errors.Join(writeErr, err)
+	}
+	if writeErr != nil {
+		// The files not written still hold what their entries describe
+		if err := manifest.Record(projectDir, out.Files[:len(written)]); err != nil {
+			return written, errors.Join(writeErr, err)
+		}
+		return written, errors.Join(writeErr, manifest.Save(projectDir))
+	}
+	if err := manifest.Replace(projectDir, out.Files); err != nil {
+		return nil, err
+	}
+	if err := manifest.Save(projectDir); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+// RenderFiles renders everything RenderAll would write without touching
+// the project directory. Files that are only seeded once (existing specs,
+// decisions, soul.local.md) are skipped when they already exist there,
+// and files shared with hand-written content only get their managed block
+// replaced. Nothing is written, so a template error leaves the project as
+// it was.
+func RenderFiles(projectDir string, cfg *config.ProjectConfig) (*Output, error) {
+	set := &fileSet{projectDir: projectDir, templates: Templates(projectDir, cfg)}
 	targets := resolveTargets(cfg)
-	var allRendered []string
+	managed, err := managedPaths(cfg, targets)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Rules.Modules.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Render target-specific artifacts (rules, workflows, orchestrator) for each target
 	for _, t := range targets {
-		files, err := renderForTarget(projectDir, cfg, t)
-		if err != nil {
+		if err := renderForTarget(set, cfg, t); err != nil {
 			return nil, fmt.Errorf("rendering for %s: %w", t.DisplayName, err)
 		}
-		allRendered = append(allRendered, files...)
 	}
 
 	// Render target-independent artifacts (multiagency) once
-	files, err := renderShared(projectDir, cfg)
-	if err != nil {
+	if err := renderShared(set, cfg); err != nil {
 		return nil, fmt.Errorf("rendering shared artifacts: %w", err)
 	}
-	allRendered = append(allRendered, files...)
 
-	return allRendered, nil
+	// Merge in the installed template packs
+	if err := renderPacks(set, cfg, targets); err != nil {
+		return nil, err
+	}
+
+	if err := applyManaged(projectDir, cfg, set.files, managed); err != nil {
+		return nil, err
+	}
+	return &Output{Files: set.files, Skipped: set.skipped}, nil
+}
+
+// WriteFiles writes rendered files into the project directory and returns
+// their paths. All files are staged next to their destination first and
+// only renamed into place once every one of them was written, so each file
+// is replaced atomically and a failure while staging writes nothing. The
+// set is not atomic: when a rename fails, the files renamed before it stay
+// in place and are returned, in order, along with the error.
+func WriteFiles(projectDir string, files []File) ([]string, error) {
+	staged := make([]string, 0, len(files))
+	var created []string // directories staging created, parents first
+	cleanup := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i]) // only succeeds while still empty
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(projectDir, f.Path)
+		created = append(created, missingDirs(filepath.Dir(destination(path)))...)
+		tmp, err := stageFile(path, f.Content)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		staged = append(staged, tmp)
+	}
+
+	written := make([]string, 0, len(files))
+	for i, f := range files {
+		if err := os.Rename(staged[i], destination(filepath.Join(projectDir, f.Path))); err != nil {
+			staged, created = staged[i:], nil
+			cleanup()
+			return written, fmt.Errorf("writing %s: %w", f.Path, err)
+		}
+		written = append(written, f.Path)
+	}
+	return written, nil
+}
+
+// fileSet collects rendered files in render order.
+type fileSet struct {
+	projectDir string
+	templates  fs.FS  // built-ins overlaid with the project's overrides
+	target     string // target being rendered
+	files      []File
+	skipped    []Skip
+}
+
+// add records content for an absolute output path inside the project.
+func (s *fileSet) add(path, template, ownership string, content []byte) {
+	rel, err := filepath.Rel(s.projectDir, path)
+	if err != nil {
+		rel = path
+	}
+	s.files = append(s.files, File{
+		Path:      rel,
+		Content:   content,
+		Template:  template,
+		Target:    s.target,
+		Ownership: ownership,
+	})
+}
+
+// addNew is add for files that must not clash with one already rendered.
+func (s *fileSet) addNew(path, template, ownership string, content []byte) error {
+	rel, err := filepath.Rel(s.projectDir, path)
+	if err != nil {
+		rel = path
+	}
+	for _, f := range s.files {
+		if f.Path == rel {
+			return fmt.Errorf("%s would overwrite %s from %s", template, rel, f.Template)
+		}
+	}
+	s.add(path, template, ownership, content)
+	return nil
+}
+
+// lookup returns the file a target rendered from a template, or nil.
+func (s *fileSet) lookup(target, template string) *File {
+	for i := range s.files {
+		if s.files[i].Target == target && s.files[i].Template == template {
+			return &s.files[i]
+		}
+	}
+	return nil
+}
+
+// skip records that the file or directory at an absolute path was left out.
+func (s *fileSet) skip(path, template, ownership string) {
+	rel, err := filepath.Rel(s.projectDir, path)
+	if err != nil {
+		rel = path
+	}
+	s.skipped = append(s.skipped, Skip{
+		Path:      rel,
+		Template:  template,
+		Target:    s.target,
+		Ownership: ownership,
+	})
 }
 
 // resolveTargets returns the targets to render for.
@@ -178,62 +397,45 @@ func resolveTargets(cfg *config.ProjectConfig) []target.Target {
 }
 
 // renderForTarget renders rules, workflows, and orchestrator for a single target.
-func renderForTarget(projectDir string, cfg *config.ProjectConfig, t target.Target) ([]string, error) {
-	data := NewTemplateData(cfg)
-	data.TargetName = t.Name
-	data.TargetDisplay = t.DisplayName
-	data.OrchestrDir = t.OrchestrDir
-
-	var rendered []string
+func renderForTarget(set *fileSet, cfg *config.ProjectConfig, t target.Target) error {
+	data := targetData(cfg, t)
+	set.target = t.Name
 
 	// 1. Render repo rules (all targets get this in the project dir)
-	repoOut := t.ResolveRepoRulesPath(projectDir)
+	repoOut := t.ResolveRepoRulesPath(set.projectDir)
 	if repoOut != "" {
-		output, err := renderTemplate("templates/repo_rules.md.tmpl", data)
+		output, err := renderTemplate(set.templates, "templates/repo_rules.md.tmpl", data)
 		if err != nil {
-			return nil, fmt.Errorf("rendering repo rules for %s: %w", t.Name, err)
-		}
-		if err := writeFile(repoOut, output); err != nil {
-			return nil, err
+			return fmt.Errorf("rendering repo rules for %s: %w", t.Name, err)
 		}
-		rel, _ := filepath.Rel(projectDir, repoOut)
-		rendered = append(rendered, rel)
+		set.add(repoOut, "repo_rules.md.tmpl", OwnerAiops, output)
 	}
 
 	// 2. Render workflows
-	workflowsDir := t.ResolveWorkflowsDir(projectDir)
+	workflowsDir := t.ResolveWorkflowsDir(set.projectDir)
 	if workflowsDir != "" {
-		wfFiles, err := renderDir("templates/windsurf/workflows", workflowsDir, data)
-		if err != nil {
-			return nil, err
-		}
-		for _, f := range wfFiles {
-			rel, _ := filepath.Rel(projectDir, f)
-			rendered = append(rendered, rel)
+		if err := renderDir(set, "templates/windsurf/workflows", workflowsDir, OwnerAiops, data); err != nil {
+			return err
 		}
 	}
 
 	// 3. Render orchestrator
-	orchestrDir := t.ResolveOrchestrDir(projectDir)
+	orchestrDir := t.ResolveOrchestrDir(set.projectDir)
 	if orchestrDir != "" {
-		orcFiles, err := renderDir("templates/windsurf/orchestrator", orchestrDir, data)
-		if err != nil {
-			return nil, err
-		}
-		for _, f := range orcFiles {
-			rel, _ := filepath.Rel(projectDir, f)
-			rendered = append(rendered, rel)
+		if err := renderDir(set, "templates/windsurf/orchestrator", orchestrDir, OwnerAiops, data); err != nil {
+			return err
 		}
 	}
 
-	return rendered, nil
+	return nil
 }
 
 // renderShared renders target-independent artifacts (multiagency, decisions).
-func renderShared(projectDir string, cfg *config.ProjectConfig) ([]string, error) {
+func renderShared(set *fileSet, cfg *config.ProjectConfig) error {
+	projectDir := set.projectDir
 	data := NewTemplateData(cfg)
 	data.TargetName = "shared"
-	var rendered []string
+	set.target = "shared"
 
 	// Render multiagency module
 	multiagencyDir := cfg.Paths.Multiagency
@@ -241,7 +443,7 @@ func renderShared(projectDir string, cfg *config.ProjectConfig) ([]string, error
 		multiagencyDir = "multiagency"
 	}
 
-	err := fs.WalkDir(templateFS, "templates/multiagency", func(path string, d fs.DirEntry, err error) error {
+	err := fs.WalkDir(set.templates, "templates/multiagency", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -253,76 +455,114 @@ func renderShared(projectDir string, cfg *config.ProjectConfig) ([]string, error
 		outPath := filepath.Join(projectDir, multiagencyDir, relPath)
 		outPath = strings.TrimSuffix(outPath, ".tmpl")
 
+		if cfg.Multiagency.SkipModule && isModuleFile(relPath) {
+			return nil
+		}
+
 		// Skip spec files that already exist (don't overwrite custom specs)
 		if strings.HasPrefix(relPath, "specs/") {
 			if _, statErr := os.Stat(outPath); statErr == nil {
+				set.skip(outPath, strings.TrimPrefix(path, "templates/"), OwnerSeedOnce)
 				return nil
 			}
 		}
 
-		output, err := renderFileContent(path, data)
+		output, err := renderFileContent(set.templates, path, data)
 		if err != nil {
 			return err
 		}
 
-		if err := writeFile(outPath, output); err != nil {
-			return err
+		if strings.HasPrefix(relPath, "specs/") && cfg.Editor.SchemaHeaders {
+			output = append([]byte(config.SchemaHeader(SpecSchemaFile)), output...)
 		}
 
-		rel, _ := filepath.Rel(projectDir, outPath)
-		rendered = append(rendered, rel)
+		// Specs are seeds the team customizes; the rest belongs to aiops
+		ownership := OwnerAiops
+		if strings.HasPrefix(relPath, "specs/") {
+			ownership = OwnerSeedOnce
+		}
+		set.add(outPath, strings.TrimPrefix(path, "templates/"), ownership, output)
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	// Copy the runtime packages into the module with its own import path
+	if !cfg.Multiagency.SkipModule {
+		sources, err := runtime.Sources(data.MultiagencyMod + "/internal/")
+		if err != nil {
+			return fmt.Errorf("reading runtime sources: %w", err)
+		}
+		for _, src := range sources {
+			source := "internal/runtime/" + strings.TrimPrefix(src.Path, "internal/")
+			set.add(filepath.Join(projectDir, multiagencyDir, filepath.FromSlash(src.Path)), source, OwnerAiops, src.Content)
+		}
+	}
+
+	// Render the schemas so the headers on specs and .aiops.yaml resolve
+	if cfg.Editor.SchemaHeaders {
+		specSchema, err := spec.JSONSchema()
+		if err != nil {
+			return fmt.Errorf("generating workflow spec schema: %w", err)
+		}
+		set.add(filepath.Join(projectDir, multiagencyDir, "specs", SpecSchemaFile), "", OwnerAiops, specSchema)
+
+		schema, err := config.JSONSchema()
+		if err != nil {
+			return fmt.Errorf("generating config schema: %w", err)
+		}
+		set.add(filepath.Join(projectDir, config.SchemaPath), "", OwnerAiops, schema)
 	}
 
 	// Render decisions directory (only the seed file, skip if decisions already exist)
 	decisionsDir := filepath.Join(projectDir, "decisions")
 	if _, statErr := os.Stat(decisionsDir); os.IsNotExist(statErr) {
-		decFiles, err := renderDir("templates/decisions", decisionsDir, data)
-		if err != nil {
-			return nil, err
-		}
-		for _, f := range decFiles {
-			rel, _ := filepath.Rel(projectDir, f)
-			rendered = append(rendered, rel)
+		if err := renderDir(set, "templates/decisions", decisionsDir, OwnerSeedOnce, data); err != nil {
+			return err
 		}
+	} else {
+		set.skip(decisionsDir, "decisions/", OwnerSeedOnce)
 	}
 
 	// Render soul files into .aiops/
 	aiopsDir := filepath.Join(projectDir, ".aiops")
 
 	// soul.md is always overwritten (owned by AIops)
-	soulContent, err := templateFS.ReadFile("templates/soul/soul.md")
+	soulContent, err := fs.ReadFile(set.templates, "templates/soul/soul.md")
 	if err != nil {
-		return nil, fmt.Errorf("reading soul.md template: %w", err)
+		return fmt.Errorf("reading soul.md template: %w", err)
 	}
-	soulPath := filepath.Join(aiopsDir, "soul.md")
-	if err := writeFile(soulPath, soulContent); err != nil {
-		return nil, err
-	}
-	rendered = append(rendered, ".aiops/soul.md")
+	set.add(filepath.Join(aiopsDir, "soul.md"), "soul/soul.md", OwnerAiops, soulContent)
 
 	// soul.local.md is only created if it doesn't exist (owned by user)
 	soulLocalPath := filepath.Join(aiopsDir, "soul.local.md")
 	if _, statErr := os.Stat(soulLocalPath); os.IsNotExist(statErr) {
-		soulLocalContent, err := templateFS.ReadFile("templates/soul/soul.local.md")
+		soulLocalContent, err := fs.ReadFile(set.templates, "templates/soul/soul.local.md")
 		if err != nil {
-			return nil, fmt.Errorf("reading soul.local.md template: %w", err)
-		}
-		if err := writeFile(soulLocalPath, soulLocalContent); err != nil {
-			return nil, err
+			return fmt.Errorf("reading soul.local.md template: %w", err)
 		}
-		rendered = append(rendered, ".aiops/soul.local.md")
+		set.add(soulLocalPath, "soul/soul.local.md", OwnerUser, soulLocalContent)
+	} else {
+		set.skip(soulLocalPath, "soul/soul.local.md", OwnerUser)
 	}
 
-	return rendered, nil
+	return nil
+}
+
+// isModuleFile reports whether a multiagency template belongs to the Go module.
+func isModuleFile(relPath string) bool {
+	for _, prefix := range moduleFiles {
+		if strings.HasPrefix(relPath, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // renderTemplate renders a single template file and returns the output bytes.
-func renderTemplate(templatePath string, data *TemplateData) ([]byte, error) {
-	content, err := templateFS.ReadFile(templatePath)
+func renderTemplate(fsys fs.FS, templatePath string, data *TemplateData) ([]byte, error) {
+	content, err := fs.ReadFile(fsys, templatePath)
 	if err != nil {
 		return nil, fmt.Errorf("reading template %s: %w", templatePath, err)
 	}
@@ -340,11 +580,9 @@ func renderTemplate(templatePath string, data *TemplateData) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// renderDir renders all files in a template directory to an output directory.
-func renderDir(templateDir, outputDir string, data *TemplateData) ([]string, error) {
-	var rendered []string
-
-	err := fs.WalkDir(templateFS, templateDir, func(path string, d fs.DirEntry, err error) error {
+// renderDir renders all files in a template directory into an output directory.
+func renderDir(set *fileSet, templateDir, outputDir, ownership string, data *TemplateData) error {
+	return fs.WalkDir(set.templates, templateDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -356,25 +594,24 @@ func renderDir(templateDir, outputDir string, data *TemplateData) ([]string, err
 		outPath := filepath.Join(outputDir, relPath)
 		outPath = strings.TrimSuffix(outPath, ".tmpl")
 
-		output, err := renderFileContent(path, data)
+		output, err := renderFileContent(set.templates, path, data)
 		if err != nil {
 			return err
 		}
 
-		if err := writeFile(outPath, output); err != nil {
-			return err
+		// x.md and x.md.tmpl render the same file, e.g. an override kept
+		// under a built-in's old name; neither may win silently
+		template := strings.TrimPrefix(path, "templates/")
+		if err := set.addNew(outPath, template, ownership, output); err != nil {
+			return fmt.Errorf("%w; rename the template override in %s to match the built-in", err, OverridesDir)
 		}
-
-		rendered = append(rendered, outPath)
 		return nil
 	})
-
-	return rendered, err
 }
 
 // renderFileContent reads and optionally templates a file.
-func renderFileContent(path string, data *TemplateData) ([]byte, error) {
-	content, err := templateFS.ReadFile(path)
+func renderFileContent(fsys fs.FS, path string, data *TemplateData) ([]byte, error) {
+	content, err := fs.ReadFile(fsys, path)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", path, err)
 	}
@@ -394,17 +631,76 @@ func renderFileContent(path string, data *TemplateData) ([]byte, error) {
 	return content, nil
 }
 
-// writeFile creates parent directories and writes content.
+// writeFile creates parent directories and replaces the file atomically.
 func writeFile(path string, content []byte) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return fmt.Errorf("creating directory for %s: %w", path, err)
+	tmp, err := stageFile(path, content)
+	if err != nil {
+		return err
 	}
-	if err := os.WriteFile(path, content, 0644); err != nil {
+	if err := os.Rename(tmp, destination(path)); err != nil {
+		os.Remove(tmp)
 		return fmt.Errorf("writing %s: %w", path, err)
 	}
 	return nil
 }
 
+// stageFile writes content to a temporary file in the directory of path,
+// with the mode of the file it will replace, and returns its name.
+func stageFile(path string, content []byte) (string, error) {
+	path = destination(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".aiops-*")
+	if err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Name(), nil
+}
+
+// missingDirs returns dir and those of its parents that do not exist yet,
+// outermost first.
+func missingDirs(dir string) []string {
+	var missing []string
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return missing
+}
+
+// destination resolves a symlinked path, so a rename replaces the file it
+// points to rather than the link.
+func destination(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
 func templateFuncs() template.FuncMap {
 	return template.FuncMap{
 		"join": strings.Join,
@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+const (
+	defaultJudgeRole = "an impartial judge reconciling independent assessments of the same task"
+	defaultJudgeGoal = "Merge the sample outputs into a single output that reflects their consensus"
+)
+
+// executeSamples runs an agent agent.Samples times concurrently and aggregates
+// the successful outputs. Every sample is kept on the result for auditing.
+func (e *Executor) executeSamples(ctx context.Context, agent *spec.Agent, task string, agentContext map[string]interface{}, llmConfig *spec.LLMConfig) (*ExecutionResult, error) {
+	results := make([]*ExecutionResult, agent.Samples)
+	errs := make([]error, agent.Samples)
+
+	var wg sync.WaitGroup
+	for i := 0; i < agent.Samples; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = e.executeOnce(ctx, agent, task, agentContext, llmConfig, i+1)
+		}(i)
+	}
+	wg.Wait()
+
+	var samples []*ExecutionResult
+	var firstErr error
+	for i, r := range results {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		samples = append(samples, r)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("agent '%s': all %d samples failed: %w", agent.ID, agent.Samples, firstErr)
+	}
+
+	result := &ExecutionResult{
+		AgentID:     agent.ID,
+		Aggregation: agent.Aggregation.StrategyOrDefault(),
+		Samples:     samples,
+	}
+	for _, s := range samples {
+		result.InputTokens += s.InputTokens
+		result.OutputTokens += s.OutputTokens
+		result.Retries += s.Retries
+		result.Repairs = append(result.Repairs, s.Repairs...)
+		result.CacheHits += s.CacheHits
+		result.CacheMisses += s.CacheMisses
+		result.CacheReadTokens += s.CacheReadTokens
+		result.CacheCreationTokens += s.CacheCreationTokens
+		result.Fallbacks = append(result.Fallbacks, s.Fallbacks...)
+	}
+
+	switch result.Aggregation {
+	case spec.AggregateJudge:
+		judged, err := e.judge(ctx, agent, task, samples, llmConfig)
+		if err != nil {
+			return nil, err
+		}
+		result.Output = judged.Output
+		result.RawResponse = judged.RawResponse
+		result.InputTokens += judged.InputTokens
+		result.OutputTokens += judged.OutputTokens
+		result.Retries += judged.Retries
+		result.Repairs = append(result.Repairs, judged.Repairs...)
+		result.CacheHits += judged.CacheHits
+		result.CacheMisses += judged.CacheMisses
+		result.CacheReadTokens += judged.CacheReadTokens
+		result.CacheCreationTokens += judged.CacheCreationTokens
+		result.Fallbacks = append(result.Fallbacks, judged.Fallbacks...)
+	default:
+		result.Output = voteOutputs(samples, &agent.OutputSchema, agent.Aggregation.ArraysOrDefault())
+		raw, _ := json.Marshal(result.Output)
+		result.RawResponse = string(raw)
+	}
+
+	return result, nil
+}
+
+// judge runs a judge agent over the samples, producing output in the original agent's schema
+func (e *Executor) judge(ctx context.Context, agent *spec.Agent, task string, samples []*ExecutionResult, llmConfig *spec.LLMConfig) (*ExecutionResult, error) {
+	judgeAgent := spec.Agent{
+		ID:           agent.ID + "_judge",
+		Role:         defaultJudgeRole,
+		Goal:         defaultJudgeGoal,
+		OutputSchema: agent.OutputSchema,
+	}
+	if j := agent.Aggregation.Judge; j != nil {
+		if j.Role != "" {
+			judgeAgent.Role = j.Role
+		}
+		if j.Goal != "" {
+			judgeAgent.Goal = j.Goal
+		}
+		judgeAgent.Constraints = j.Constraints
+	}
+	judgeAgent.Constraints = append(judgeAgent.Constraints,
+		fmt.Sprintf("The samples are independent answers from %s to: %s", agent.Role, agent.Goal),
+		"Prefer points most samples agree on; include minority points only when well supported",
+	)
+
+	samplesContext := make(map[string]interface{}, len(samples))
+	for i, s := range samples {
+		samplesContext[fmt.Sprintf("sample_%d", i+1)] = s.Output
+	}
+
+	result, err := e.executeOnce(ctx, &judgeAgent, task, samplesContext, llmConfig, 0)
+	if err != nil {
+		return nil, fmt.Errorf("agent '%s' judge failed: %w", agent.ID, err)
+	}
+	return result, nil
+}
+
+// voteOutputs merges sample outputs: enum and boolean fields by majority vote,
+// arrays by union or intersection, and everything else from the sample that
+// agrees with the most votes.
+func voteOutputs(samples []*ExecutionResult, schema *spec.OutputSchema, arrays string) map[string]interface{} {
+	voted := make(map[string]interface{})
+	var arrayFields []string
+
+	for name, field := range schema.Properties {
+		switch {
+		case field.Type == "boolean", field.Type == "string" && len(field.Enum) > 0:
+			if value, ok := majority(samples, name); ok {
+				voted[name] = value
+			}
+		case field.Type == "array":
+			arrayFields = append(arrayFields, name)
+		}
+	}
+
+	// Start from the most representative sample, then overlay the votes
+	merged := make(map[string]interface{})
+	for k, v := range representative(samples, voted).Output {
+		merged[k] = v
+	}
+	for k, v := range voted {
+		merged[k] = v
+	}
+	for _, name := range arrayFields {
+		if value, ok := mergeArrays(samples, name, arrays); ok {
+			merged[name] = value
+		}
+	}
+
+	return merged
+}
+
+// majority returns the most common value of a field; ties go to the value seen first
+func majority(samples []*ExecutionResult, name string) (interface{}, bool) {
+	counts := make(map[string]int)
+	values := make(map[string]interface{})
+	var order []string
+
+	for _, s := range samples {
+		value, ok := s.Output[name]
+		if !ok {
+			continue
+		}
+		key := canonical(value)
+		if _, seen := counts[key]; !seen {
+			order = append(order, key)
+			values[key] = value
+		}
+		counts[key]++
+	}
+	if len(order) == 0 {
+		return nil, false
+	}
+
+	best := order[0]
+	for _, key := range order[1:] {
+		if counts[key] > counts[best] {
+			best = key
+		}
+	}
+	return values[best], true
+}
+
+// mergeArrays unions or intersects an array field across samples, keeping first-seen order
+func mergeArrays(samples []*ExecutionResult, name, mode string) ([]interface{}, bool) {
+	counts := make(map[string]int)
+	var order []interface{}
+	present := 0
+
+	for _, s := range samples {
+		items, ok := s.Output[name].([]interface{})
+		if !ok {
+			continue
+		}
+		present++
+		seen := make(map[string]bool)
+		for _, item := range items {
+			key := canonical(item)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if counts[key] == 0 {
+				order = append(order, item)
+			}
+			counts[key]++
+		}
+	}
+	if present == 0 {
+		return nil, false
+	}
+
+	merged := make([]interface{}, 0, len(order))
+	for _, item := range order {
+		if mode == spec.ArraysIntersection && counts[canonical(item)] < present {
+			continue
+		}
+		merged = append(merged, item)
+	}
+	return merged, true
+}
+
+// representative returns the first sample that agrees with the most voted fields
+func representative(samples []*ExecutionResult, voted map[string]interface{}) *ExecutionResult {
+	best, bestScore := samples[0], -1
+	for _, s := range samples {
+		score := 0
+		for name, value := range voted {
+			if v, ok := s.Output[name]; ok && canonical(v) == canonical(value) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best
+}
+
+// canonical returns a comparable encoding of a JSON value (map keys are sorted by encoding/json)
+func canonical(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,282 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// Executor executes a single agent
+type Executor struct {
+	client        llm.Client
+	promptBuilder *PromptBuilder
+	maxRetries    int
+}
+
+// NewExecutor creates a new agent executor
+func NewExecutor(client llm.Client) *Executor {
+	return &Executor{
+		client:        client,
+		promptBuilder: NewPromptBuilder(),
+		maxRetries:    3,
+	}
+}
+
+// ExecutionResult represents the result of an agent execution
+type ExecutionResult struct {
+	AgentID      string                 `json:"agent_id"`
+	Output       map[string]interface{} `json:"output"`
+	RawResponse  string                 `json:"raw_response"`
+	InputTokens  int                    `json:"input_tokens"`
+	OutputTokens int                    `json:"output_tokens"`
+	Retries      int                    `json:"retries"`
+	Repairs      []string               `json:"repairs,omitempty"` // JSON repair steps applied to the accepted response
+	CacheHits    int                    `json:"cache_hits,omitempty"`
+	CacheMisses  int                    `json:"cache_misses,omitempty"`
+
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
+
+	Provider  string                 `json:"provider,omitempty"`  // provider that answered when a fallback chain is configured
+	Fallbacks []llm.FallbackDecision `json:"fallbacks,omitempty"` // provider switches across all attempts
+
+	Aggregation string             `json:"aggregation,omitempty"`
+	Samples     []*ExecutionResult `json:"samples,omitempty"`
+}
+
+// Execute runs a single agent with the given task and context. Agents with
+// samples > 1 are run concurrently and their outputs aggregated.
+func (e *Executor) Execute(ctx context.Context, agent *spec.Agent, task string, agentContext map[string]interface{}, llmConfig *spec.LLMConfig) (*ExecutionResult, error) {
+	if agent.Samples > 1 {
+		return e.executeSamples(ctx, agent, task, agentContext, llmConfig)
+	}
+	return e.executeOnce(ctx, agent, task, agentContext, llmConfig, 0)
+}
+
+// executeOnce runs one LLM call (plus retries) for an agent. sample numbers
+// the call within a multi-sample run and is 0 otherwise.
+func (e *Executor) executeOnce(ctx context.Context, agent *spec.Agent, task string, agentContext map[string]interface{}, llmConfig *spec.LLMConfig, sample int) (*ExecutionResult, error) {
+	prompts := e.promptBuilder.BuildPrompts(agent, task, agentContext, llmConfig)
+	systemPrompt, sharedContext, userPrompt := prompts.System, prompts.Shared, prompts.User
+
+	var outputSchema map[string]interface{}
+	if llmConfig.OutputMode == spec.OutputModeTool {
+		outputSchema = agent.OutputSchema.ToJSONSchema()
+		// Tool input must be an object, and the API requires the type even
+		// when the agent declares no output_schema
+		outputSchema["type"] = "object"
+	}
+
+	var lastErr error
+	var lastResponse string
+	var cacheHits, cacheMisses int
+	var cacheRead, cacheCreation int
+	var fallbacks []llm.FallbackDecision
+
+	for retry := 0; retry <= e.maxRetries; retry++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		req := &llm.Request{
+			SystemPrompt:  systemPrompt,
+			UserPrompt:    userPrompt,
+			SharedContext: sharedContext,
+			CachePrompt:   llmConfig.PromptCaching,
+			OutputSchema:  outputSchema,
+			Model:         llmConfig.Model,
+			Temperature:   llmConfig.Temperature,
+			MaxTokens:     llmConfig.MaxTokens,
+			Sample:        sample,
+		}
+
+		var truncated *errTruncated
+		if retry > 0 && errors.As(lastErr, &truncated) {
+			req.UserPrompt = fmt.Sprintf("%s\n\nPREVIOUS ATTEMPT FAILED:\nError: %s\n\nRespond with a shorter answer: keep descriptions brief and include only the most important items. Respond with valid JSON only.",
+				userPrompt, lastErr.Error())
+		} else if retry > 0 && lastErr != nil {
+			req.UserPrompt = fmt.Sprintf("%s\n\nPREVIOUS ATTEMPT FAILED:\nError: %s\nYour response was: %s\n\nPlease fix the error and respond with valid JSON only.",
+				userPrompt, lastErr.Error(), lastResponse)
+		}
+
+		resp, err := e.client.Complete(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("LLM call failed: %w", err)
+			continue
+		}
+
+		lastResponse = resp.Content
+		cacheRead += resp.CacheReadTokens
+		cacheCreation += resp.CacheCreationTokens
+		switch resp.Cache {
+		case llm.CacheHit:
+			cacheHits++
+		case llm.CacheMiss:
+			cacheMisses++
+		}
+		if resp.Cache != llm.CacheHit {
+			fallbacks = append(fallbacks, resp.Fallbacks...)
+		}
+
+		output, repairs, err := e.parseResponse(resp, llmConfig)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to parse response as JSON: %w", err)
+			e.forget(req)
+			continue
+		}
+
+		if err := e.validateOutput(output, &agent.OutputSchema); err != nil {
+			lastErr = fmt.Errorf("output validation failed: %w", err)
+			e.forget(req)
+			continue
+		}
+
+		result := &ExecutionResult{
+			AgentID:      agent.ID,
+			Output:       output,
+			RawResponse:  resp.Content,
+			InputTokens:  resp.InputTokens,
+			OutputTokens: resp.OutputTokens,
+			Retries:      retry,
+			Repairs:      repairs,
+			CacheHits:    cacheHits,
+			CacheMisses:  cacheMisses,
+
+			CacheReadTokens:     cacheRead,
+			CacheCreationTokens: cacheCreation,
+
+			Provider:  resp.Provider,
+			Fallbacks: fallbacks,
+		}
+		if resp.Cache == llm.CacheHit {
+			// Served from cache: no tokens were spent on this call
+			result.InputTokens, result.OutputTokens = 0, 0
+			result.CacheReadTokens, result.CacheCreationTokens = 0, 0
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("agent '%s' failed after %d retries: %w", agent.ID, e.maxRetries, lastErr)
+}
+
+// forget drops a rejected response from the response cache, if any, so the
+// next run asks the model again instead of replaying it
+func (e *Executor) forget(req *llm.Request) {
+	if f, ok := e.client.(llm.Forgetter); ok {
+		f.Forget(req)
+	}
+}
+
+// parseResponse decodes a response as a JSON object, running the repair
+// pass on malformed output. Output cut off at max_tokens is reported as
+// truncated instead, so the retry can ask for a shorter answer.
+func (e *Executor) parseResponse(resp *llm.Response, llmConfig *spec.LLMConfig) (map[string]interface{}, []string, error) {
+	if resp.StopReason == stopMaxTokens {
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(resp.Content)), &result); err != nil {
+			return nil, nil, &errTruncated{maxTokens: llmConfig.MaxTokens}
+		}
+		return result, nil, nil
+	}
+	return repairJSON(resp.Content)
+}
+
+func extractJSONFromMarkdown(content string) string {
+	patterns := []string{"```json\n", "```\n"}
+	for _, pattern := range patterns {
+		start := strings.Index(content, pattern)
+		if start != -1 {
+			start += len(pattern)
+			end := strings.Index(content[start:], "```")
+			if end != -1 {
+				return strings.TrimSpace(content[start : start+end])
+			}
+		}
+	}
+	return content
+}
+
+func (e *Executor) validateOutput(output map[string]interface{}, schema *spec.OutputSchema) error {
+	if schema == nil {
+		return nil
+	}
+
+	for _, required := range schema.Required {
+		if _, ok := output[required]; !ok {
+			return fmt.Errorf("missing required field: %s", required)
+		}
+	}
+
+	for name, field := range schema.Properties {
+		value, exists := output[name]
+		if !exists {
+			continue
+		}
+		if err := e.validateField(name, value, &field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Executor) validateField(name string, value interface{}, field *spec.SchemaField) error {
+	if value == nil {
+		return nil
+	}
+
+	switch field.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("field '%s' must be a string", name)
+		}
+		if len(field.Enum) > 0 {
+			strVal := value.(string)
+			valid := false
+			for _, allowed := range field.Enum {
+				if strVal == allowed {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return fmt.Errorf("field '%s' must be one of %v", name, field.Enum)
+			}
+		}
+	case "number", "integer":
+		switch value.(type) {
+		case float64, int, int64:
+		default:
+			return fmt.Errorf("field '%s' must be a number", name)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("field '%s' must be a boolean", name)
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("field '%s' must be an array", name)
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("field '%s' must be an object", name)
+		}
+	}
+
+	return nil
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
@@ -3,9 +3,10 @@ package agent
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
-	"{{.MultiagencyMod}}/internal/spec"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
 )
 
 // PromptBuilder builds prompts for agent execution
@@ -16,6 +17,35 @@ func NewPromptBuilder() *PromptBuilder {
 	return &PromptBuilder{}
 }
 
+// Prompts are the prompts an agent is sent for one call
+type Prompts struct {
+	System string
+	Shared string // task and upstream outputs, split out only when prompt caching is on
+	User   string
+}
+
+// BuildPrompts builds the prompts exactly as the executor sends them. With
+// prompt caching the shared context is kept separate so providers can cache
+// it; otherwise it leads the user prompt.
+func (b *PromptBuilder) BuildPrompts(agent *spec.Agent, task string, context map[string]interface{}, llmConfig *spec.LLMConfig) Prompts {
+	p := Prompts{
+		System: b.BuildSystemPrompt(agent),
+		Shared: b.BuildSharedContext(task, context),
+		User:   b.BuildInstructions(&agent.OutputSchema),
+	}
+	if !llmConfig.PromptCaching {
+		p.User = p.Shared + p.User
+		p.Shared = ""
+	}
+	return p
+}
+
+// MockOutput returns a placeholder output shaped like an agent's output
+// schema, standing in for upstream agents when previewing prompts
+func (b *PromptBuilder) MockOutput(schema *spec.OutputSchema) interface{} {
+	return b.buildSchemaExample(schema)
+}
+
 // BuildSystemPrompt builds the system prompt for an agent
 func (b *PromptBuilder) BuildSystemPrompt(agent *spec.Agent) string {
 	var sb strings.Builder
@@ -40,15 +70,36 @@ func (b *PromptBuilder) BuildSystemPrompt(agent *spec.Agent) string {
 
 // BuildUserPrompt builds the user prompt for an agent
 func (b *PromptBuilder) BuildUserPrompt(task string, context map[string]interface{}, outputSchema *spec.OutputSchema) string {
+	return b.BuildSharedContext(task, context) + b.BuildInstructions(outputSchema)
+}
+
+// MemoryContextKey holds an agent's recalled memory in its context, as a
+// map from agent_id.field to the values saved by earlier runs
+const MemoryContextKey = "_memory"
+
+// BuildSharedContext builds the stable part of the user prompt: the task and
+// upstream outputs. Context is written in sorted order so identical inputs
+// produce byte-identical prompts, which prompt and response caches rely on.
+func (b *PromptBuilder) BuildSharedContext(task string, context map[string]interface{}) string {
 	var sb strings.Builder
 
 	sb.WriteString("TASK:\n")
 	sb.WriteString(task)
 	sb.WriteString("\n\n")
 
-	if len(context) > 0 {
+	memory, _ := context[MemoryContextKey].(map[string][]interface{})
+	agentIDs := make([]string, 0, len(context))
+	for agentID := range context {
+		if agentID != MemoryContextKey {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+	sort.Strings(agentIDs)
+
+	if len(agentIDs) > 0 {
 		sb.WriteString("CONTEXT FROM PREVIOUS AGENTS:\n")
-		for agentID, output := range context {
+		for _, agentID := range agentIDs {
+			output := context[agentID]
 			sb.WriteString(fmt.Sprintf("\n--- Output from %s ---\n", agentID))
 			jsonOutput, err := json.MarshalIndent(output, "", "  ")
 			if err != nil {
@@ -61,6 +112,36 @@ func (b *PromptBuilder) BuildUserPrompt(task string, context map[string]interfac
 		sb.WriteString("\n")
 	}
 
+	if len(memory) > 0 {
+		b.writeMemory(&sb, memory)
+	}
+
+	return sb.String()
+}
+
+// writeMemory renders values saved by earlier runs as settled context
+func (b *PromptBuilder) writeMemory(sb *strings.Builder, memory map[string][]interface{}) {
+	keys := make([]string, 0, len(memory))
+	for key := range memory {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sb.WriteString("MEMORY FROM PREVIOUS RUNS:\n")
+	sb.WriteString("These items were recorded by earlier runs of this workflow and are already settled. Do not raise them again unless the task shows they have changed.\n")
+	for _, key := range keys {
+		sb.WriteString(fmt.Sprintf("\n--- %s ---\n", key))
+		jsonValues, _ := json.MarshalIndent(memory[key], "", "  ")
+		sb.WriteString(string(jsonValues))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+}
+
+// BuildInstructions builds the agent-specific tail of the user prompt
+func (b *PromptBuilder) BuildInstructions(outputSchema *spec.OutputSchema) string {
+	var sb strings.Builder
+
 	sb.WriteString("OUTPUT FORMAT (JSON):\n")
 	schemaJSON := b.schemaToExample(outputSchema)
 	sb.WriteString(schemaJSON)
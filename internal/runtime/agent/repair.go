@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Repair steps recorded on ExecutionResult.Repairs
+const (
+	RepairMarkdown       = "markdown_fence"
+	RepairExtractObject  = "extract_object"
+	RepairTrailingCommas = "trailing_commas"
+	RepairSingleQuotes   = "single_quotes"
+)
+
+// stopMaxTokens is the stop reason providers report when output hit max_tokens
+const stopMaxTokens = "max_tokens"
+
+// errTruncated reports output cut off at max_tokens. Closing the open
+// brackets would silently drop whatever the model had left to say, so
+// truncated output is never repaired; the retry asks for a shorter answer.
+type errTruncated struct {
+	maxTokens int
+}
+
+func (e *errTruncated) Error() string {
+	if e.maxTokens > 0 {
+		return fmt.Sprintf("response was truncated at max_tokens (%d)", e.maxTokens)
+	}
+	return "response was truncated at max_tokens"
+}
+
+// repairJSON parses content as a JSON object, applying increasingly tolerant
+// repair steps until one parses. It returns the object and the steps that
+// were needed; no steps means the content was valid as-is.
+func repairJSON(content string) (map[string]interface{}, []string, error) {
+	content = strings.TrimSpace(content)
+
+	var result map[string]interface{}
+	firstErr := json.Unmarshal([]byte(content), &result)
+	if firstErr == nil {
+		return result, nil, nil
+	}
+
+	var steps []string
+	if strings.Contains(content, "```") {
+		if fenced := extractJSONFromMarkdown(content); fenced != content {
+			content = fenced
+			steps = append(steps, RepairMarkdown)
+			if json.Unmarshal([]byte(content), &result) == nil {
+				return result, steps, nil
+			}
+		}
+	}
+
+	if object := largestObject(content); object != "" && object != content {
+		content = object
+		steps = append(steps, RepairExtractObject)
+		if json.Unmarshal([]byte(content), &result) == nil {
+			return result, steps, nil
+		}
+	}
+
+	if fixed, fixes := fixSyntax(content); len(fixes) > 0 {
+		content = fixed
+		steps = append(steps, fixes...)
+		if json.Unmarshal([]byte(content), &result) == nil {
+			return result, steps, nil
+		}
+	}
+
+	return nil, steps, fmt.Errorf("invalid JSON: %w (content: %s)", firstErr, truncate(content, 200))
+}
+
+// largestObject returns the longest balanced {...} span in s, skipping
+// braces inside double-quoted strings. Prose before or after the object and
+// smaller example objects the model echoed are dropped.
+func largestObject(s string) string {
+	best := ""
+	for start := 0; start < len(s); start++ {
+		if s[start] != '{' {
+			continue
+		}
+		end := matchBrace(s, start)
+		if end < 0 {
+			continue
+		}
+		if end-start+1 > len(best) {
+			best = s[start : end+1]
+		}
+		start = end
+	}
+	return best
+}
+
+// matchBrace returns the index of the brace closing the one at start, or -1
+func matchBrace(s string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				if c != '}' {
+					return -1
+				}
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// fixSyntax rewrites the slips models commonly make in otherwise valid JSON:
+// trailing commas before a closing bracket and single-quoted strings. It
+// returns the rewritten text and the repair steps that changed something.
+func fixSyntax(s string) (string, []string) {
+	var b strings.Builder
+	var commas, quotes bool
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			end := stringEnd(s, i, '"')
+			b.WriteString(s[i:end])
+			i = end - 1
+		case '\'':
+			end := stringEnd(s, i, '\'')
+			b.WriteString(requote(s[i:end]))
+			i = end - 1
+			quotes = true
+		case ',':
+			j := i + 1
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				commas = true
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	var steps []string
+	if commas {
+		steps = append(steps, RepairTrailingCommas)
+	}
+	if quotes {
+		steps = append(steps, RepairSingleQuotes)
+	}
+	return b.String(), steps
+}
+
+// stringEnd returns the index just past the string literal opened by quote
+// at start, or len(s) when it is unterminated
+func stringEnd(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// requote turns a single-quoted literal into a double-quoted one
+func requote(literal string) string {
+	body := strings.TrimPrefix(literal, "'")
+	body = strings.TrimSuffix(body, "'")
+	body = strings.ReplaceAll(body, `\'`, `'`)
+	body = strings.ReplaceAll(body, `\"`, `"`)
+	body = strings.ReplaceAll(body, `"`, `\"`)
+	return `"` + body + `"`
+}
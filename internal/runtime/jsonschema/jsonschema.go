@@ -0,0 +1,212 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON Schema dialect emitted by Generate.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or sub-schema.
+type Schema map[string]interface{}
+
+// Generate builds a JSON Schema for the Go value v by reflecting over its
+// yaml struct tags. Named struct types are emitted once under $defs so that
+// recursive types (e.g. nested schema fields) terminate.
+//
+// Struct fields may carry a `jsonschema` tag with comma-separated options:
+//
+//	required             field must be present
+//	enum=a|b|c           allowed string values
+//	description=...      human-readable description (must be last)
+func Generate(v interface{}, id, title string) Schema {
+	g := &generator{defs: map[string]Schema{}}
+	root := g.reflectStruct(reflect.TypeOf(v))
+
+	root["$schema"] = Draft
+	if id != "" {
+		root["$id"] = id
+	}
+	if title != "" {
+		root["title"] = title
+	}
+	if len(g.defs) > 0 {
+		root["$defs"] = g.defs
+	}
+	return root
+}
+
+// Marshal renders a schema as indented JSON.
+func Marshal(s Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Typer lets a type describe its own schema, for types with custom YAML
+// unmarshalling (durations, string-or-list fields, ...).
+type Typer interface {
+	JSONSchema() map[string]interface{}
+}
+
+var typerType = reflect.TypeOf((*Typer)(nil)).Elem()
+
+type generator struct {
+	defs map[string]Schema
+}
+
+func (g *generator) reflectType(t reflect.Type) Schema {
+	if t.Implements(typerType) {
+		return Schema(reflect.Zero(t).Interface().(Typer).JSONSchema())
+	}
+	if reflect.PointerTo(t).Implements(typerType) {
+		return Schema(reflect.New(t).Interface().(Typer).JSONSchema())
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.reflectType(t.Elem())
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.reflectType(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.reflectType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.reflectStruct(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = Schema{} // placeholder breaks recursion
+			g.defs[t.Name()] = g.reflectStruct(t)
+		}
+		return Schema{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return Schema{}
+	}
+}
+
+func (g *generator) reflectStruct(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	props := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, inline := yamlName(f)
+		if name == "-" {
+			continue
+		}
+		if inline {
+			inner := g.reflectStruct(f.Type)
+			for k, v := range inner["properties"].(map[string]interface{}) {
+				props[k] = v
+			}
+			if req, ok := inner["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+
+		prop := g.reflectType(f.Type)
+		opts := parseTag(f.Tag.Get("jsonschema"))
+		if opts.description != "" {
+			prop = withKey(prop, "description", opts.description)
+		}
+		if len(opts.enum) > 0 {
+			prop = withKey(prop, "enum", opts.enum)
+		}
+		if opts.required {
+			required = append(required, name)
+		}
+		props[name] = prop
+	}
+
+	s := Schema{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// withKey adds a keyword to a schema. $ref siblings are wrapped in allOf so
+// older validators that ignore keywords next to $ref still see them.
+func withKey(s Schema, key string, value interface{}) Schema {
+	if ref, ok := s["$ref"]; ok && len(s) == 1 {
+		return Schema{"allOf": []interface{}{Schema{"$ref": ref}}, key: value}
+	}
+	s[key] = value
+	return s
+}
+
+func yamlName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("yaml")
+	if tag == "" {
+		return strings.ToLower(f.Name), false
+	}
+	parts := strings.Split(tag, ",")
+	inline := false
+	for _, p := range parts[1:] {
+		if p == "inline" {
+			inline = true
+		}
+	}
+	name := parts[0]
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, inline
+}
+
+type tagOptions struct {
+	required    bool
+	enum        []string
+	description string
+}
+
+func parseTag(tag string) tagOptions {
+	var opts tagOptions
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "description=") {
+			// description is free text and always last
+			part, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+
+		switch {
+		case part == "required":
+			opts.required = true
+		case strings.HasPrefix(part, "enum="):
+			opts.enum = strings.Split(strings.TrimPrefix(part, "enum="), "|")
+		case strings.HasPrefix(part, "description="):
+			opts.description = strings.TrimPrefix(part, "description=")
+		}
+	}
+	return opts
+}
@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	anthropicAPIURL     = "https://api.anthropic.com/v1/messages"
+	anthropicAPIVersion = "2023-06-01"
+
+	// outputToolName is the forced tool used for structured output
+	outputToolName = "submit_output"
+)
+
+// AnthropicClient implements the Client interface for Anthropic's Claude API
+type AnthropicClient struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewAnthropicClient creates a new Anthropic client
+func NewAnthropicClient(apiKey string) *AnthropicClient {
+	return &AnthropicClient{
+		apiKey: apiKey,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float64              `json:"temperature"`
+	System      []anthropicContent   `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicContent struct {
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text"`
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicResponse struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Role         string `json:"role"`
+	Model        string `json:"model"`
+	StopReason   string `json:"stop_reason"`
+	StopSequence string `json:"stop_sequence"`
+	Content      []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// buildRequest maps a Request to the Messages API. With CachePrompt the
+// system prompt and shared context get cache breakpoints; with an
+// OutputSchema the model is forced to answer through a tool call.
+func buildRequest(req *Request) anthropicRequest {
+	var cacheControl *anthropicCacheControl
+	if req.CachePrompt {
+		cacheControl = &anthropicCacheControl{Type: "ephemeral"}
+	}
+
+	body := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+
+	if req.SystemPrompt != "" {
+		system := anthropicContent{Type: "text", Text: req.SystemPrompt, CacheControl: cacheControl}
+		body.System = []anthropicContent{system}
+	}
+
+	var userContent []anthropicContent
+	if req.SharedContext != "" {
+		userContent = append(userContent, anthropicContent{Type: "text", Text: req.SharedContext, CacheControl: cacheControl})
+	}
+	userContent = append(userContent, anthropicContent{Type: "text", Text: req.UserPrompt})
+	body.Messages = []anthropicMessage{
+		{Role: "user", Content: userContent},
+	}
+
+	if req.OutputSchema != nil {
+		tool := anthropicTool{
+			Name:        outputToolName,
+			Description: "Submit the final output. The input must match the required output format.",
+			InputSchema: req.OutputSchema,
+		}
+		body.Tools = []anthropicTool{tool}
+		body.ToolChoice = &anthropicToolChoice{Type: "tool", Name: outputToolName}
+	}
+
+	return body
+}
+
+// Complete sends a request to the Anthropic API
+func (c *AnthropicClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	body := buildRequest(req)
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{Provider: "anthropic", StatusCode: resp.StatusCode, Message: string(respBody)}
+		var errBody anthropicError
+		if err := json.Unmarshal(respBody, &errBody); err == nil {
+			apiErr.Type = errBody.Error.Type
+			apiErr.Message = errBody.Error.Message
+		}
+		return nil, apiErr
+	}
+
+	var apiResp anthropicResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	content := ""
+	for _, c := range apiResp.Content {
+		switch {
+		case c.Type == "tool_use" && c.Name == outputToolName:
+			// Structured output: the tool input is the agent's JSON output
+			content = string(c.Input)
+		case c.Type == "text" && req.OutputSchema == nil:
+			content += c.Text
+		}
+	}
+
+	return &Response{
+		Content:      content,
+		Model:        apiResp.Model,
+		InputTokens:  apiResp.Usage.InputTokens,
+		OutputTokens: apiResp.Usage.OutputTokens,
+		StopReason:   apiResp.StopReason,
+
+		CacheCreationTokens: apiResp.Usage.CacheCreationInputTokens,
+		CacheReadTokens:     apiResp.Usage.CacheReadInputTokens,
+	}, nil
+}
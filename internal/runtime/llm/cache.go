@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache lookup outcomes reported in Response.Cache
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// DefaultCacheDir is where cached responses are stored unless configured otherwise
+const DefaultCacheDir = ".multiagency/cache"
+
+// CacheOptions configures the response cache
+type CacheOptions struct {
+	Dir             string
+	Provider        string        // part of the cache key, so providers never share entries
+	TTL             time.Duration // 0 means entries never expire
+	MaxBytes        int64         // 0 means no size limit
+	AllTemperatures bool          // also cache requests with temperature > 0
+}
+
+// CacheStats counts cache lookups
+type CacheStats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// CachedClient wraps a Client with a content-addressed on-disk response cache
+type CachedClient struct {
+	client Client
+	opts   CacheOptions
+	mu     sync.Mutex
+	stats  CacheStats
+
+	// size is the running total of the cache directory, known once the
+	// first write has scanned it; sizeMu keeps lookups off the scan
+	sizeMu sync.Mutex
+	size   int64
+	sized  bool
+}
+
+// NewCachedClient wraps client with a response cache
+func NewCachedClient(client Client, opts CacheOptions) *CachedClient {
+	if opts.Dir == "" {
+		opts.Dir = DefaultCacheDir
+	}
+	return &CachedClient{client: client, opts: opts}
+}
+
+// Forgetter is implemented by clients that can drop a stored response, so
+// a response the caller rejects is not replayed on the next run
+type Forgetter interface {
+	Forget(req *Request)
+}
+
+// Complete returns a cached response when one exists, otherwise calls the
+// wrapped client and stores the result. Responses cut off at max_tokens and
+// answers from a fallback provider are not stored.
+func (c *CachedClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	if !c.cacheable(req) {
+		return c.client.Complete(ctx, req)
+	}
+
+	path := c.entryPath(CacheKey(c.opts.Provider, req))
+
+	if resp, ok := c.read(path); ok {
+		c.record(true)
+		resp.Cache = CacheHit
+		return resp, nil
+	}
+	c.record(false)
+
+	resp, err := c.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.storable(resp) {
+		// A failed write only costs a future cache miss
+		if added, err := c.write(path, resp); err == nil {
+			_ = c.evict(added)
+		}
+	}
+
+	resp.Cache = CacheMiss
+	return resp, nil
+}
+
+// Forget removes the stored response for req, e.g. one that failed to parse
+func (c *CachedClient) Forget(req *Request) {
+	if c.cacheable(req) {
+		os.Remove(c.entryPath(CacheKey(c.opts.Provider, req)))
+	}
+}
+
+// Stats returns the hit and miss counts so far
+func (c *CachedClient) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// CacheKey returns the content address of a request to a provider: a hash
+// of everything that influences the response
+func CacheKey(provider string, req *Request) string {
+	key, _ := json.Marshal(struct {
+		Provider      string                 `json:"provider,omitempty"`
+		Model         string                 `json:"model"`
+		SystemPrompt  string                 `json:"system_prompt"`
+		SharedContext string                 `json:"shared_context,omitempty"`
+		UserPrompt    string                 `json:"user_prompt"`
+		Temperature   float64                `json:"temperature"`
+		MaxTokens     int                    `json:"max_tokens"`
+		OutputSchema  map[string]interface{} `json:"output_schema,omitempty"`
+		Sample        int                    `json:"sample,omitempty"`
+	}{provider, req.Model, req.SystemPrompt, req.SharedContext, req.UserPrompt, req.Temperature, req.MaxTokens, req.OutputSchema, req.Sample})
+
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheable reports whether responses to req are cached at all
+func (c *CachedClient) cacheable(req *Request) bool {
+	return c.opts.AllTemperatures || req.Temperature == 0
+}
+
+// storable reports whether resp may be stored under the primary provider's
+// key. A fallback's answer would otherwise be replayed as the primary's.
+func (c *CachedClient) storable(resp *Response) bool {
+	if resp.StopReason == "max_tokens" || len(resp.Fallbacks) > 0 {
+		return false
+	}
+	return resp.Provider == "" || resp.Provider == c.opts.Provider
+}
+
+func (c *CachedClient) record(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
+
+func (c *CachedClient) entryPath(key string) string {
+	return filepath.Join(c.opts.Dir, key[:2], key+".json")
+}
+
+func (c *CachedClient) read(path string) (*Response, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if c.opts.TTL > 0 && time.Since(info.ModTime()) > c.opts.TTL {
+		os.Remove(path)
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var resp Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+// write stores resp at path and returns how many bytes the cache grew by
+func (c *CachedClient) write(path string, resp *Response) (int64, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	added := int64(len(data))
+	if info, err := os.Stat(path); err == nil {
+		added -= info.Size()
+	}
+
+	// Write via a unique temp file and rename, so concurrent requests never
+	// read a partial entry or clobber each other's temp file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return added, os.Rename(tmp.Name(), path)
+}
+
+// evict removes the oldest entries until the cache fits in MaxBytes. The
+// directory is only walked on the first write and when the running size
+// goes over the limit; writes by other processes are picked up then.
+func (c *CachedClient) evict(added int64) error {
+	if c.opts.MaxBytes <= 0 {
+		return nil
+	}
+
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+
+	if c.sized {
+		c.size += added
+		if c.size <= c.opts.MaxBytes {
+			return nil
+		}
+	}
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+
+	err := filepath.WalkDir(c.opts.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entry{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if total <= c.opts.MaxBytes {
+			break
+		}
+		if os.Remove(e.path) == nil {
+			total -= e.size
+		}
+	}
+	c.size, c.sized = total, true
+	return nil
+}
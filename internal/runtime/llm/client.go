@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Client defines the interface for LLM providers
+type Client interface {
+	Complete(ctx context.Context, req *Request) (*Response, error)
+}
+
+// Request represents a request to the LLM
+type Request struct {
+	SystemPrompt string  `json:"system_prompt"`
+	UserPrompt   string  `json:"user_prompt"`
+	Model        string  `json:"model"`
+	Temperature  float64 `json:"temperature"`
+	MaxTokens    int     `json:"max_tokens"`
+	Sample       int     `json:"sample,omitempty"` // sample number for multi-sample agents, keeps cached samples distinct
+
+	// SharedContext is stable context (task, upstream outputs) sent before
+	// UserPrompt. Providers that support it cache it when CachePrompt is set.
+	SharedContext string `json:"shared_context,omitempty"`
+	CachePrompt   bool   `json:"cache_prompt,omitempty"`
+
+	// OutputSchema requests structured output matching this JSON Schema
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+}
+
+// Response represents a response from the LLM
+type Response struct {
+	Content      string `json:"content"`
+	Model        string `json:"model"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	StopReason   string `json:"stop_reason"`
+	Cache        string `json:"cache,omitempty"` // "hit" or "miss" when served through CachedClient
+
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"` // prompt tokens written to the provider's cache
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`     // prompt tokens read from the provider's cache
+
+	Provider  string             `json:"provider,omitempty"`  // set by FallbackClient to the provider that answered
+	Fallbacks []FallbackDecision `json:"fallbacks,omitempty"` // providers skipped before this one answered
+}
+
+// EstimateTokens approximates the token count of text at ~4 characters per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// NewClient creates a new LLM client based on the provider
+func NewClient(provider string, apiKey string) (Client, error) {
+	switch provider {
+	case "anthropic":
+		return NewAnthropicClient(apiKey), nil
+	case "ollama":
+		return NewOllamaClient(""), nil
+	case "stub":
+		return NewStubClient(), nil
+	default:
+		return nil, &ProviderError{Provider: provider, Message: "unsupported provider"}
+	}
+}
+
+// ProviderError represents an error with the LLM provider
+type ProviderError struct {
+	Provider string
+	Message  string
+}
+
+func (e *ProviderError) Error() string {
+	return "llm provider error (" + e.Provider + "): " + e.Message
+}
+
+// APIError is an error response from a provider's HTTP API
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s API error (%d): %s - %s", e.Provider, e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// ShouldFallback reports whether err means the provider cannot serve the
+// request right now: a quota or rate limit error, or a client error that
+// retrying with the same provider will not fix. Server errors and network
+// failures are left to the caller's retry loop.
+func ShouldFallback(err error) bool {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return true
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusRequestTimeout, http.StatusConflict:
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Provider is one entry of a fallback chain
+type Provider struct {
+	Name   string
+	Model  string // overrides Request.Model; empty keeps the request's model
+	Client Client
+}
+
+func (p Provider) label(req *Request) string {
+	model := p.Model
+	if model == "" {
+		model = req.Model
+	}
+	return p.Name + "/" + model
+}
+
+// FallbackDecision records a switch from a failing provider to the next one
+type FallbackDecision struct {
+	From   string `json:"from"` // provider/model that failed
+	To     string `json:"to"`   // provider/model tried next
+	Reason string `json:"reason"`
+}
+
+// FallbackClient tries providers in order, moving on when one fails with an
+// error ShouldFallback accepts. Other errors are returned immediately so the
+// caller's retry loop can handle them.
+type FallbackClient struct {
+	providers []Provider
+}
+
+// NewFallbackClient creates a client over an ordered provider chain
+func NewFallbackClient(providers []Provider) *FallbackClient {
+	return &FallbackClient{providers: providers}
+}
+
+// Complete sends the request to the first provider that can serve it
+func (c *FallbackClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	var decisions []FallbackDecision
+	var failures []string
+
+	for i, p := range c.providers {
+		attempt := *req
+		if p.Model != "" {
+			attempt.Model = p.Model
+		}
+
+		resp, err := p.Client.Complete(ctx, &attempt)
+		if err == nil {
+			resp.Provider = p.Name
+			resp.Fallbacks = decisions
+			return resp, nil
+		}
+		if !ShouldFallback(err) {
+			return nil, err
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", p.label(req), err))
+		if i+1 < len(c.providers) {
+			decisions = append(decisions, FallbackDecision{
+				From:   p.label(req),
+				To:     c.providers[i+1].label(req),
+				Reason: err.Error(),
+			})
+		}
+	}
+
+	return nil, fmt.Errorf("all providers failed: %s", strings.Join(failures, "; "))
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOllamaHost is where a local Ollama server listens unless OLLAMA_HOST says otherwise
+const DefaultOllamaHost = "http://127.0.0.1:11434"
+
+// OllamaClient implements the Client interface for a local Ollama server,
+// so specs can run against a model on the developer's machine
+type OllamaClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOllamaClient creates a client for the Ollama server at host, e.g.
+// "localhost:11434" or "http://gpu-box:11434"; empty means DefaultOllamaHost
+func NewOllamaClient(host string) *OllamaClient {
+	if host == "" {
+		host = DefaultOllamaHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return &OllamaClient{
+		baseURL: strings.TrimRight(host, "/"),
+		httpClient: &http.Client{
+			// Local models can be slow to load and to answer
+			Timeout: 10 * time.Minute,
+		},
+	}
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   interface{}     `json:"format,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// Complete sends a request to the Ollama chat API. With an OutputSchema the
+// model's output is constrained to the schema.
+func (c *OllamaClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	body := ollamaRequest{
+		Model:   req.Model,
+		Options: ollamaOptions{Temperature: req.Temperature, NumPredict: req.MaxTokens},
+	}
+	if req.SystemPrompt != "" {
+		body.Messages = append(body.Messages, ollamaMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	user := req.UserPrompt
+	if req.SharedContext != "" {
+		user = req.SharedContext + "\n\n" + user
+	}
+	body.Messages = append(body.Messages, ollamaMessage{Role: "user", Content: user})
+	if req.OutputSchema != nil {
+		body.Format = req.OutputSchema
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request (is ollama running at %s?): %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{Provider: "ollama", StatusCode: resp.StatusCode, Message: string(respBody)}
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &errBody); err == nil && errBody.Error != "" {
+			apiErr.Message = errBody.Error
+		}
+		return nil, apiErr
+	}
+
+	var apiResp ollamaResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	stopReason := apiResp.DoneReason
+	if stopReason == "length" {
+		// Report truncation the way the agent executor expects it
+		stopReason = "max_tokens"
+	}
+
+	return &Response{
+		Content:      apiResp.Message.Content,
+		Model:        apiResp.Model,
+		InputTokens:  apiResp.PromptEvalCount,
+		OutputTokens: apiResp.EvalCount,
+		StopReason:   stopReason,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateWindow is the span requests and tokens are counted over
+const rateWindow = time.Minute
+
+// limitKey identifies a rate limit configuration
+type limitKey struct{ rpm, tpm int }
+
+var (
+	processLimitersMu sync.Mutex
+	processLimiters   = map[limitKey]*Limiter{}
+)
+
+// ProcessLimiter returns the limiter shared by every client in the process
+// configured with the same limits. Specs with different rate_limit settings
+// get separate limiters, so one spec's limits never carry over to another.
+func ProcessLimiter(rpm, tpm int) *Limiter {
+	processLimitersMu.Lock()
+	defer processLimitersMu.Unlock()
+	key := limitKey{rpm, tpm}
+	if l, ok := processLimiters[key]; ok {
+		return l
+	}
+	l := &Limiter{rpm: rpm, tpm: tpm}
+	processLimiters[key] = l
+	return l
+}
+
+// Limiter enforces requests-per-minute and tokens-per-minute over a sliding
+// one-minute window. Zero limits are unlimited.
+type Limiter struct {
+	mu    sync.Mutex
+	rpm   int
+	tpm   int
+	calls []*Reservation
+}
+
+// Reservation is a call admitted by a Limiter
+type Reservation struct {
+	at     time.Time
+	tokens int
+}
+
+// Wait blocks until a call with the estimated token count fits in the window,
+// then reserves it. The returned call is corrected with Done once the real
+// usage is known.
+func (l *Limiter) Wait(ctx context.Context, tokens int) (*Reservation, error) {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.prune(now)
+
+		wait := l.delay(now, tokens)
+		if wait == 0 {
+			call := &Reservation{at: now, tokens: tokens}
+			l.calls = append(l.calls, call)
+			l.mu.Unlock()
+			return call, nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Done replaces a reservation's estimate with the tokens actually used
+func (l *Limiter) Done(call *Reservation, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	call.tokens = tokens
+}
+
+func (l *Limiter) prune(now time.Time) {
+	keep := l.calls[:0]
+	for _, c := range l.calls {
+		if now.Sub(c.at) < rateWindow {
+			keep = append(keep, c)
+		}
+	}
+	l.calls = keep
+}
+
+// delay returns how long until a call of the given size fits, or 0 if it fits now.
+// A call larger than the whole token budget is let through once the window is empty.
+func (l *Limiter) delay(now time.Time, tokens int) time.Duration {
+	if len(l.calls) == 0 {
+		return 0
+	}
+	if l.rpm > 0 && len(l.calls) >= l.rpm {
+		return l.calls[len(l.calls)-l.rpm].at.Add(rateWindow).Sub(now)
+	}
+	if l.tpm <= 0 {
+		return 0
+	}
+
+	used := 0
+	for _, c := range l.calls {
+		used += c.tokens
+	}
+	if used+tokens <= l.tpm {
+		return 0
+	}
+	// Wait until enough of the oldest calls leave the window
+	for _, c := range l.calls {
+		used -= c.tokens
+		if used+tokens <= l.tpm {
+			return c.at.Add(rateWindow).Sub(now)
+		}
+	}
+	return l.calls[len(l.calls)-1].at.Add(rateWindow).Sub(now)
+}
+
+// RateLimitedClient holds each call until the shared Limiter admits it
+type RateLimitedClient struct {
+	client  Client
+	limiter *Limiter
+}
+
+// NewRateLimitedClient wraps client with limiter
+func NewRateLimitedClient(client Client, limiter *Limiter) *RateLimitedClient {
+	return &RateLimitedClient{client: client, limiter: limiter}
+}
+
+// Complete waits for capacity, then calls the wrapped client
+func (c *RateLimitedClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	call, err := c.limiter.Wait(ctx, estimateTokens(req))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.limiter.Done(call, resp.InputTokens+resp.OutputTokens)
+	return resp, nil
+}
+
+// estimateTokens approximates a request's prompt size
+func estimateTokens(req *Request) int {
+	return EstimateTokens(req.SystemPrompt) + EstimateTokens(req.SharedContext) + EstimateTokens(req.UserPrompt)
+}
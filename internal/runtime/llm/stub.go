@@ -31,7 +31,7 @@ func (c *StubClient) Complete(ctx context.Context, req *Request) (*Response, err
 		return &Response{
 			Content:      resp,
 			Model:        "stub",
-			InputTokens:  len(req.SystemPrompt) + len(req.UserPrompt),
+			InputTokens:  len(req.SystemPrompt) + len(req.SharedContext) + len(req.UserPrompt),
 			OutputTokens: len(resp),
 			StopReason:   "end_turn",
 		}, nil
@@ -42,7 +42,7 @@ func (c *StubClient) Complete(ctx context.Context, req *Request) (*Response, err
 	return &Response{
 		Content:      stubResponse,
 		Model:        "stub",
-		InputTokens:  len(req.SystemPrompt) + len(req.UserPrompt),
+		InputTokens:  len(req.SystemPrompt) + len(req.SharedContext) + len(req.UserPrompt),
 		OutputTokens: len(stubResponse),
 		StopReason:   "end_turn",
 	}, nil
@@ -0,0 +1,235 @@
+// Package memory stores agent output fields across pipeline runs, so later
+// runs can be told what earlier runs already settled
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is one remembered value. Array fields are stored item by item;
+// seeing the same value again bumps LastSeen and Count instead of adding
+// a duplicate.
+type Entry struct {
+	ID        string      `json:"id"` // hash of field and value
+	Field     string      `json:"field"`
+	Value     interface{} `json:"value"`
+	Task      string      `json:"task,omitempty"` // task of the run that first saved it
+	FirstSeen time.Time   `json:"first_seen"`
+	LastSeen  time.Time   `json:"last_seen"`
+	Count     int         `json:"count"`
+}
+
+// Scope identifies the entries of one agent in one spec
+type Scope struct {
+	Spec  string `json:"spec"`
+	Agent string `json:"agent"`
+}
+
+// Store keeps entries as JSON files at <dir>/<spec>/<agent>.json
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the store's root directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// DefaultDir returns .aiops/memory in the nearest directory at or above the
+// working directory that holds .aiops.yaml, or in the working directory
+// when there is none
+func DefaultDir() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return filepath.Join(".aiops", "memory")
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".aiops.yaml")); err == nil {
+			return filepath.Join(dir, ".aiops", "memory")
+		}
+		if filepath.Dir(dir) == dir {
+			return filepath.Join(cwd, ".aiops", "memory")
+		}
+	}
+}
+
+// Key turns a workflow name into the directory name of its memory,
+// e.g. "Code Review Workflow" becomes code-review-workflow
+func Key(workflowName string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(workflowName) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func (s *Store) path(scope Scope) string {
+	return filepath.Join(s.dir, scope.Spec, scope.Agent+".json")
+}
+
+// Load returns the entries of a scope, most recently seen first
+func (s *Store) Load(scope Scope) ([]Entry, error) {
+	data, err := os.ReadFile(s.path(scope))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path(scope), err)
+	}
+	return entries, nil
+}
+
+// save writes the entries of a scope, already sorted most recently seen
+// first, removing the file when there are none
+func (s *Store) save(scope Scope, entries []Entry) error {
+	path := s.path(scope)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Record saves the given fields of an agent's output and keeps at most
+// maxEntries entries. It returns how many entries were new.
+func (s *Store) Record(scope Scope, task string, output map[string]interface{}, fields []string, maxEntries int) (int, error) {
+	entries, err := s.Load(scope)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[string]int, len(entries))
+	for i, e := range entries {
+		byID[e.ID] = i
+	}
+
+	now := time.Now().UTC()
+	added := 0
+	for _, field := range fields {
+		value, ok := output[field]
+		if !ok || value == nil {
+			continue
+		}
+		values := []interface{}{value}
+		if items, ok := value.([]interface{}); ok {
+			values = items
+		}
+		for _, v := range values {
+			id := entryID(field, v)
+			if i, ok := byID[id]; ok {
+				entries[i].LastSeen = now
+				entries[i].Count++
+				continue
+			}
+			byID[id] = len(entries)
+			entries = append(entries, Entry{
+				ID: id, Field: field, Value: v, Task: task,
+				FirstSeen: now, LastSeen: now, Count: 1,
+			})
+			added++
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastSeen.After(entries[j].LastSeen)
+	})
+	if maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+	}
+	return added, s.save(scope, entries)
+}
+
+// Scopes lists the scopes with saved entries, optionally limited to one spec
+func (s *Store) Scopes(spec string) ([]Scope, error) {
+	pattern := filepath.Join(s.dir, "*", "*.json")
+	if spec != "" {
+		pattern = filepath.Join(s.dir, spec, "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make([]Scope, 0, len(paths))
+	for _, path := range paths {
+		scopes = append(scopes, Scope{
+			Spec:  filepath.Base(filepath.Dir(path)),
+			Agent: strings.TrimSuffix(filepath.Base(path), ".json"),
+		})
+	}
+	return scopes, nil
+}
+
+// Prune removes entries last seen longer than olderThan ago (when positive)
+// and keeps at most keep entries (when positive). It returns how many
+// entries were removed.
+func (s *Store) Prune(scope Scope, olderThan time.Duration, keep int) (int, error) {
+	entries, err := s.Load(scope)
+	if err != nil {
+		return 0, err
+	}
+	kept := entries[:0]
+	cutoff := time.Now().Add(-olderThan)
+	for _, e := range entries {
+		if olderThan > 0 && e.LastSeen.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if keep > 0 && len(kept) > keep {
+		kept = kept[:keep]
+	}
+	removed := len(entries) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save(scope, kept)
+}
+
+// Clear removes every entry of a scope and returns how many there were
+func (s *Store) Clear(scope Scope) (int, error) {
+	entries, err := s.Load(scope)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), s.save(scope, nil)
+}
+
+// entryID hashes a field and its JSON value so equal values share an entry
+func entryID(field string, value interface{}) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(append([]byte(field+"\x00"), data...))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// NewClient creates the LLM client for a spec, reading API keys from the
+// environment. Fallback providers and the shared rate limiter are applied
+// when configured, and the response cache wraps everything unless disabled
+// in the spec or by noCache.
+func NewClient(llmConfig *spec.LLMConfig, noCache bool) (llm.Client, error) {
+	var limiter *llm.Limiter
+	if rl := llmConfig.RateLimit; rl != nil && (rl.RequestsPerMinute > 0 || rl.TokensPerMinute > 0) {
+		limiter = llm.ProcessLimiter(rl.RequestsPerMinute, rl.TokensPerMinute)
+	}
+
+	client, err := newProviderClient(llmConfig.Provider, limiter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(llmConfig.Fallback) > 0 {
+		primary := llm.Provider{Name: llmConfig.Provider, Client: client}
+		providers := []llm.Provider{primary}
+		for _, fb := range llmConfig.Fallback {
+			fbClient, err := newProviderClient(fb.Provider, limiter)
+			if err != nil {
+				return nil, fmt.Errorf("fallback %s/%s: %w", fb.Provider, fb.Model, err)
+			}
+			providers = append(providers, llm.Provider{Name: fb.Provider, Model: fb.Model, Client: fbClient})
+		}
+		client = llm.NewFallbackClient(providers)
+	}
+
+	cache := llmConfig.Cache
+	if cache == nil {
+		cache = &spec.Cache{}
+	}
+	if noCache || cache.Disabled {
+		return client, nil
+	}
+
+	maxSizeMB := cache.MaxSizeMB
+	if maxSizeMB == 0 {
+		maxSizeMB = 100
+	}
+	return llm.NewCachedClient(client, llm.CacheOptions{
+		Dir:             cache.Dir,
+		Provider:        llmConfig.Provider,
+		TTL:             cache.TTL.Duration,
+		MaxBytes:        int64(maxSizeMB) << 20,
+		AllTemperatures: cache.AllTemperatures,
+	}), nil
+}
+
+// newProviderClient creates the client for a single provider, rate limited
+// when limiter is set
+func newProviderClient(provider string, limiter *llm.Limiter) (llm.Client, error) {
+	var client llm.Client
+	var err error
+
+	switch provider {
+	case "cascade":
+		return nil, fmt.Errorf("provider 'cascade' only runs inside the IDE; use --provider anthropic, ollama or stub")
+	case "anthropic":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY is not set")
+		}
+		client, err = llm.NewClient(provider, apiKey)
+	case "ollama":
+		client = llm.NewOllamaClient(os.Getenv("OLLAMA_HOST"))
+	default:
+		client, err = llm.NewClient(provider, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter != nil {
+		client = llm.NewRateLimitedClient(client, limiter)
+	}
+	return client, nil
+}
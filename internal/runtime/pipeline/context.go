@@ -4,7 +4,7 @@ import (
 	"sync"
 	"time"
 
-	"{{.MultiagencyMod}}/internal/agent"
+	"github.com/voltic-software/aiops/internal/runtime/agent"
 )
 
 // ExecutionContext holds the state during pipeline execution
@@ -22,6 +22,21 @@ type ExecutionContext struct {
 type TokenUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	CacheHits    int `json:"cache_hits,omitempty"`
+	CacheMisses  int `json:"cache_misses,omitempty"`
+
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
+}
+
+// Add accumulates another usage into u
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheHits += other.CacheHits
+	u.CacheMisses += other.CacheMisses
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
 }
 
 // NewExecutionContext creates a new execution context
@@ -43,8 +58,15 @@ func (c *ExecutionContext) SetOutput(agentID string, result *agent.ExecutionResu
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.outputs[agentID] = result
-	c.totalTokens.InputTokens += result.InputTokens
-	c.totalTokens.OutputTokens += result.OutputTokens
+	c.totalTokens.Add(TokenUsage{
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		CacheHits:    result.CacheHits,
+		CacheMisses:  result.CacheMisses,
+
+		CacheReadTokens:     result.CacheReadTokens,
+		CacheCreationTokens: result.CacheCreationTokens,
+	})
 }
 
 // GetOutput retrieves the output from a specific agent
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"time"
+
+	"github.com/voltic-software/aiops/internal/runtime/agent"
+)
+
+// Event types reported to an observer while a pipeline runs
+const (
+	EventPipelineStarted   = "pipeline_started"
+	EventAgentStarted      = "agent_started"
+	EventAgentCompleted    = "agent_completed"
+	EventPipelineCompleted = "pipeline_completed"
+	EventPipelineCancelled = "pipeline_cancelled"
+	EventPipelineFailed    = "pipeline_failed"
+)
+
+// Event is a progress notification from a running pipeline
+type Event struct {
+	Type     string                 `json:"type"`
+	Time     time.Time              `json:"time"`
+	Workflow string                 `json:"workflow"`
+	Agent    string                 `json:"agent,omitempty"`
+	Index    int                    `json:"index,omitempty"`  // 1-based position of Agent in the spec
+	Total    int                    `json:"total"`            // agents in the spec
+	Error    string                 `json:"error,omitempty"`  // pipeline_cancelled and pipeline_failed
+	Result   *agent.ExecutionResult `json:"result,omitempty"` // agent_completed
+}
+
+// SetObserver registers a function called synchronously for every event.
+// It runs on the pipeline goroutine, so it should return quickly.
+func (e *Executor) SetObserver(observer func(Event)) {
+	e.observer = observer
+}
+
+func (e *Executor) emit(event Event) {
+	if e.observer == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.Workflow = e.spec.Name
+	event.Total = len(e.spec.Agents)
+	e.observer(event)
+}
@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/voltic-software/aiops/internal/runtime/agent"
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/memory"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// Executor orchestrates the execution of a multi-agent pipeline
+type Executor struct {
+	spec          *spec.WorkflowSpec
+	llmClient     llm.Client
+	agentExecutor *agent.Executor
+	output        io.Writer
+	verbose       bool
+	inputs        map[string]interface{}
+	observer      func(Event)
+	memory        *memory.Store
+}
+
+// NewExecutor creates a new pipeline executor
+func NewExecutor(workflowSpec *spec.WorkflowSpec, llmClient llm.Client) *Executor {
+	return &Executor{
+		spec:          workflowSpec,
+		llmClient:     llmClient,
+		agentExecutor: agent.NewExecutor(llmClient),
+		output:        os.Stdout,
+		verbose:       false,
+	}
+}
+
+// SetOutput sets the output writer for progress messages
+func (e *Executor) SetOutput(w io.Writer) {
+	e.output = w
+}
+
+// SetVerbose enables verbose output
+func (e *Executor) SetVerbose(verbose bool) {
+	e.verbose = verbose
+}
+
+// SetInputs sets external context passed to every agent alongside upstream outputs
+func (e *Executor) SetInputs(inputs map[string]interface{}) {
+	e.inputs = inputs
+}
+
+// Pipeline statuses
+const (
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled" // interrupted or past the pipeline timeout; outputs are partial
+)
+
+// PipelineResult represents the final result of a pipeline execution
+type PipelineResult struct {
+	WorkflowName string                            `json:"workflow_name"`
+	Task         string                            `json:"task"`
+	Status       string                            `json:"status"`
+	Error        string                            `json:"error,omitempty"` // why the pipeline was cancelled
+	FinalOutput  map[string]interface{}            `json:"final_output"`
+	AllOutputs   map[string]*agent.ExecutionResult `json:"all_outputs,omitempty"`
+	TokenUsage   TokenUsage                        `json:"token_usage"`
+	DurationMs   int64                             `json:"duration_ms"`
+	AgentCount   int                               `json:"agent_count"`
+}
+
+// Execute runs the entire pipeline. When ctx is cancelled or the spec's
+// timeout passes, it stops between or during agents and returns the outputs
+// completed so far with status cancelled rather than an error. An agent that
+// fails or exceeds its own timeout still fails the pipeline.
+func (e *Executor) Execute(ctx context.Context, task string) (*PipelineResult, error) {
+	if timeout := e.spec.Timeout.Duration; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("pipeline timeout of %s exceeded", timeout))
+		defer cancel()
+	}
+
+	execCtx := NewExecutionContext(task)
+
+	e.log("Starting workflow: %s\n", e.spec.Name)
+	e.log("Task: %s\n", task)
+	e.log("Agents: %d\n\n", len(e.spec.Agents))
+	e.emit(Event{Type: EventPipelineStarted})
+
+	for i, agentSpec := range e.spec.Agents {
+		if ctx.Err() != nil {
+			return e.cancelled(ctx, execCtx, agentSpec.ID), nil
+		}
+
+		execCtx.SetCurrentAgent(agentSpec.ID)
+
+		e.log("[%d/%d] Executing agent: %s\n", i+1, len(e.spec.Agents), agentSpec.ID)
+		e.log("  Role: %s\n", agentSpec.Role)
+		e.emit(Event{Type: EventAgentStarted, Agent: agentSpec.ID, Index: i + 1})
+
+		agentContext := execCtx.GetOutputsFor(agentSpec.InputFrom)
+		if len(agentSpec.InputFrom) > 0 {
+			e.log("  Using context from: %v\n", agentSpec.InputFrom)
+		}
+		for key, value := range e.inputs {
+			agentContext[key] = value
+		}
+		e.recall(&agentSpec, agentContext)
+
+		result, err := e.executeAgent(ctx, &agentSpec, task, agentContext)
+		if err != nil {
+			if ctx.Err() != nil {
+				return e.cancelled(ctx, execCtx, agentSpec.ID), nil
+			}
+			err = fmt.Errorf("agent '%s' failed: %w", agentSpec.ID, err)
+			e.emit(Event{Type: EventPipelineFailed, Agent: agentSpec.ID, Index: i + 1, Error: err.Error()})
+			return nil, err
+		}
+
+		execCtx.SetOutput(agentSpec.ID, result)
+		e.remember(&agentSpec, task, result)
+		e.emit(Event{Type: EventAgentCompleted, Agent: agentSpec.ID, Index: i + 1, Result: result})
+
+		e.log("  ✓ Completed (tokens: %d in, %d out", result.InputTokens, result.OutputTokens)
+		if result.Retries > 0 {
+			e.log(", retries: %d", result.Retries)
+		}
+		if len(result.Repairs) > 0 {
+			e.log(", json repairs: %d", len(result.Repairs))
+		}
+		if result.CacheHits > 0 {
+			e.log(", cache hits: %d", result.CacheHits)
+		}
+		if len(result.Fallbacks) > 0 {
+			e.log(", fallbacks: %d", len(result.Fallbacks))
+		}
+		if len(result.Samples) > 0 {
+			e.log(", samples: %d/%d via %s", len(result.Samples), agentSpec.Samples, result.Aggregation)
+		}
+		e.log(")\n")
+		for _, fb := range result.Fallbacks {
+			e.log("  ↪ %s → %s: %s\n", fb.From, fb.To, fb.Reason)
+		}
+
+		if e.verbose {
+			outputJSON, _ := json.MarshalIndent(result.Output, "    ", "  ")
+			e.log("  Output: %s\n", string(outputJSON))
+		}
+		e.log("\n")
+	}
+
+	execCtx.Complete()
+
+	lastAgent := e.spec.Agents[len(e.spec.Agents)-1]
+	finalResult, _ := execCtx.GetOutput(lastAgent.ID)
+
+	result := e.newResult(execCtx, StatusCompleted)
+	result.FinalOutput = finalResult.Output
+
+	e.log("Pipeline completed in %dms\n", result.DurationMs)
+	e.logUsage(result.TokenUsage)
+	e.emit(Event{Type: EventPipelineCompleted})
+
+	return result, nil
+}
+
+// executeAgent runs one agent, bounded by its timeout when set
+func (e *Executor) executeAgent(ctx context.Context, agentSpec *spec.Agent, task string, agentContext map[string]interface{}) (*agent.ExecutionResult, error) {
+	timeout := agentSpec.Timeout.Duration
+	if timeout <= 0 {
+		return e.agentExecutor.Execute(ctx, agentSpec, task, agentContext, &e.spec.LLM)
+	}
+
+	agentCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := e.agentExecutor.Execute(agentCtx, agentSpec, task, agentContext, &e.spec.LLM)
+	if err != nil && ctx.Err() == nil && agentCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+	return result, err
+}
+
+// cancelled builds the partial result of an interrupted pipeline
+func (e *Executor) cancelled(ctx context.Context, execCtx *ExecutionContext, agentID string) *PipelineResult {
+	execCtx.Complete()
+
+	result := e.newResult(execCtx, StatusCancelled)
+	result.Error = fmt.Sprintf("cancelled before agent '%s' completed: %v", agentID, context.Cause(ctx))
+
+	e.log("\n⚠ Pipeline %s after %dms: %s\n", result.Status, result.DurationMs, result.Error)
+	e.log("  Completed agents: %d/%d\n", len(result.AllOutputs), len(e.spec.Agents))
+	e.logUsage(result.TokenUsage)
+	e.emit(Event{Type: EventPipelineCancelled, Agent: agentID, Error: result.Error})
+
+	return result
+}
+
+func (e *Executor) newResult(execCtx *ExecutionContext, status string) *PipelineResult {
+	return &PipelineResult{
+		WorkflowName: e.spec.Name,
+		Task:         execCtx.Task(),
+		Status:       status,
+		AllOutputs:   execCtx.AllOutputs(),
+		TokenUsage:   execCtx.TotalTokens(),
+		DurationMs:   execCtx.Duration().Milliseconds(),
+		AgentCount:   len(e.spec.Agents),
+	}
+}
+
+func (e *Executor) logUsage(usage TokenUsage) {
+	e.log("Total tokens: %d input, %d output\n", usage.InputTokens, usage.OutputTokens)
+	if usage.CacheReadTokens > 0 || usage.CacheCreationTokens > 0 {
+		e.log("Prompt cache: %d tokens read, %d written\n", usage.CacheReadTokens, usage.CacheCreationTokens)
+	}
+	if usage.CacheHits > 0 || usage.CacheMisses > 0 {
+		e.log("Cache: %d hits, %d misses\n", usage.CacheHits, usage.CacheMisses)
+	}
+}
+
+func (e *Executor) log(format string, args ...interface{}) {
+	fmt.Fprintf(e.output, format, args...)
+}
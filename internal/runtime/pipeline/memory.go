@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"github.com/voltic-software/aiops/internal/runtime/agent"
+	"github.com/voltic-software/aiops/internal/runtime/memory"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// SetMemory enables agent memory: agents with a memory: block recall entries
+// saved by earlier runs and save their chosen output fields to store
+func (e *Executor) SetMemory(store *memory.Store) {
+	e.memory = store
+}
+
+// recall adds the memory an agent recalls to its context. Memory is
+// advisory, so a store that cannot be read only logs a warning.
+func (e *Executor) recall(agentSpec *spec.Agent, agentContext map[string]interface{}) {
+	if e.memory == nil {
+		return
+	}
+
+	recalled := make(map[string][]interface{})
+	count := 0
+	for _, id := range agentSpec.Memory.RecallOrDefault(agentSpec.ID) {
+		entries, err := e.memory.Load(memory.Scope{Spec: memory.Key(e.spec.Name), Agent: id})
+		if err != nil {
+			e.log("  ⚠ memory: %v\n", err)
+			continue
+		}
+		for _, entry := range entries {
+			key := id + "." + entry.Field
+			recalled[key] = append(recalled[key], entry.Value)
+			count++
+		}
+	}
+	if count > 0 {
+		agentContext[agent.MemoryContextKey] = recalled
+		e.log("  Recalled %d memory entries\n", count)
+	}
+}
+
+// remember saves the output fields an agent is configured to keep
+func (e *Executor) remember(agentSpec *spec.Agent, task string, result *agent.ExecutionResult) {
+	if e.memory == nil || agentSpec.Memory == nil || len(agentSpec.Memory.Save) == 0 {
+		return
+	}
+
+	scope := memory.Scope{Spec: memory.Key(e.spec.Name), Agent: agentSpec.ID}
+	added, err := e.memory.Record(scope, task, result.Output, agentSpec.Memory.Save, agentSpec.Memory.MaxEntriesOrDefault())
+	if err != nil {
+		e.log("  ⚠ memory: %v\n", err)
+		return
+	}
+	if added > 0 {
+		e.log("  Saved %d new memory entries\n", added)
+	}
+}
@@ -0,0 +1,251 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/memory"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// Output fields the manager spec's classifier uses to recommend a workflow
+const (
+	fieldRecommendedTier     = "recommended_tier"
+	fieldRecommendedWorkflow = "recommended_workflow"
+	fieldRationale           = "rationale"
+)
+
+// Handoff records the manager's routing decision and what was passed on
+type Handoff struct {
+	From      string `json:"from"`
+	To        string `json:"to,omitempty"`
+	Agent     string `json:"agent"`
+	Tier      string `json:"tier"`
+	Workflow  string `json:"workflow"`
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// RouteResult combines the manager run and the workflow it routed to
+type RouteResult struct {
+	Task       string          `json:"task"`
+	Status     string          `json:"status"`
+	Handoff    Handoff         `json:"handoff"`
+	Manager    *PipelineResult `json:"manager"`
+	Workflow   *PipelineResult `json:"workflow,omitempty"`
+	TokenUsage TokenUsage      `json:"token_usage"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// ClientFactory creates the LLM client for a spec's llm settings
+type ClientFactory func(llmConfig *spec.LLMConfig) (llm.Client, error)
+
+// Router runs the manager spec and chains the workflow its classifier recommends
+type Router struct {
+	specsDir    string
+	managerFile string
+	newClient   ClientFactory
+	output      io.Writer
+	verbose     bool
+	provider    string
+	model       string
+	memory      *memory.Store
+}
+
+// NewRouter creates a router that resolves workflows relative to specsDir.
+// Each spec runs on a client built from its own llm settings by newClient.
+func NewRouter(specsDir string, newClient ClientFactory) *Router {
+	return &Router{
+		specsDir:    specsDir,
+		managerFile: "manager.yaml",
+		newClient:   newClient,
+		output:      os.Stdout,
+	}
+}
+
+// SetManager sets the manager spec file name (default manager.yaml)
+func (r *Router) SetManager(file string) {
+	r.managerFile = file
+}
+
+// SetOutput sets the output writer for progress messages
+func (r *Router) SetOutput(w io.Writer) {
+	r.output = w
+}
+
+// SetVerbose enables verbose output
+func (r *Router) SetVerbose(verbose bool) {
+	r.verbose = verbose
+}
+
+// SetLLMOverride overrides the provider and model of every spec the router runs
+func (r *Router) SetLLMOverride(provider, model string) {
+	r.provider = provider
+	r.model = model
+}
+
+// SetMemory enables agent memory for every spec the router runs
+func (r *Router) SetMemory(store *memory.Store) {
+	r.memory = store
+}
+
+// Route runs the manager pipeline, then the recommended workflow with the
+// classifier's output passed in as context
+func (r *Router) Route(ctx context.Context, task string) (*RouteResult, error) {
+	start := time.Now()
+
+	managerSpec, err := r.load(r.managerFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading manager spec: %w", err)
+	}
+
+	managerExecutor, err := r.newExecutor(managerSpec)
+	if err != nil {
+		return nil, err
+	}
+	managerResult, err := managerExecutor.Execute(ctx, task)
+	if err != nil {
+		return nil, fmt.Errorf("manager pipeline failed: %w", err)
+	}
+	if managerResult.Status == StatusCancelled {
+		return &RouteResult{
+			Task:       task,
+			Status:     StatusCancelled,
+			Manager:    managerResult,
+			TokenUsage: managerResult.TokenUsage,
+			DurationMs: time.Since(start).Milliseconds(),
+		}, nil
+	}
+
+	handoff, classification, err := extractHandoff(managerSpec, managerResult)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RouteResult{
+		Task:       task,
+		Status:     StatusCompleted,
+		Handoff:    handoff,
+		Manager:    managerResult,
+		TokenUsage: managerResult.TokenUsage,
+	}
+
+	if handoff.Workflow == "" || handoff.Workflow == "none" {
+		fmt.Fprintf(r.output, "Manager recommends tier %q with no workflow — nothing to chain.\n", handoff.Tier)
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result, nil
+	}
+
+	if handoff.Workflow == filepath.Base(r.managerFile) {
+		return nil, fmt.Errorf("manager recommended itself (%s); refusing to loop", handoff.Workflow)
+	}
+	if err := r.checkWorkflow(handoff.Workflow); err != nil {
+		return nil, err
+	}
+
+	workflowSpec, err := r.load(handoff.Workflow)
+	if err != nil {
+		return nil, fmt.Errorf("loading recommended workflow %q: %w", handoff.Workflow, err)
+	}
+	result.Handoff.To = workflowSpec.Name
+
+	fmt.Fprintf(r.output, "→ Routing to %s (%s)\n\n", handoff.Workflow, workflowSpec.Name)
+
+	executor, err := r.newExecutor(workflowSpec)
+	if err != nil {
+		return nil, err
+	}
+	executor.SetInputs(map[string]interface{}{handoff.Agent: classification})
+
+	workflowResult, err := executor.Execute(ctx, task)
+	if err != nil {
+		return nil, fmt.Errorf("workflow %q failed: %w", handoff.Workflow, err)
+	}
+
+	result.Workflow = workflowResult
+	result.Status = workflowResult.Status
+	result.TokenUsage.Add(workflowResult.TokenUsage)
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	return result, nil
+}
+
+func (r *Router) load(file string) (*spec.WorkflowSpec, error) {
+	workflowSpec, err := spec.LoadFromFile(filepath.Join(r.specsDir, file))
+	if err != nil {
+		return nil, err
+	}
+	workflowSpec.LLM.Override(r.provider, r.model)
+	return workflowSpec, nil
+}
+
+// checkWorkflow accepts only the file name of a spec in the specs
+// directory, since the name comes from model output
+func (r *Router) checkWorkflow(name string) error {
+	ext := filepath.Ext(name)
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || (ext != ".yaml" && ext != ".yml") {
+		return fmt.Errorf("manager recommended workflow %q, which is not a spec file name", name)
+	}
+	info, err := os.Stat(filepath.Join(r.specsDir, name))
+	if err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("manager recommended workflow %q but it is not in %s", name, r.specsDir)
+	}
+	return nil
+}
+
+// newExecutor creates an executor for a spec on a client built from the
+// spec's own llm settings
+func (r *Router) newExecutor(workflowSpec *spec.WorkflowSpec) (*Executor, error) {
+	client, err := r.newClient(&workflowSpec.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", workflowSpec.Name, err)
+	}
+	executor := NewExecutor(workflowSpec, client)
+	executor.SetOutput(r.output)
+	executor.SetVerbose(r.verbose)
+	executor.SetMemory(r.memory)
+	return executor, nil
+}
+
+// extractHandoff finds the agent output carrying recommended_workflow. The
+// first agent in spec order that produced it wins, so custom manager specs
+// work as long as one agent emits the field. When none did, the first agent
+// with a recommended_tier is taken to recommend no workflow.
+func extractHandoff(managerSpec *spec.WorkflowSpec, result *PipelineResult) (Handoff, map[string]interface{}, error) {
+	for _, field := range []string{fieldRecommendedWorkflow, fieldRecommendedTier} {
+		if handoff, output, ok := findHandoff(managerSpec, result, field); ok {
+			return handoff, output, nil
+		}
+	}
+	return Handoff{}, nil, fmt.Errorf("no agent in %q produced %s or %s", managerSpec.Name, fieldRecommendedWorkflow, fieldRecommendedTier)
+}
+
+// findHandoff builds the handoff from the first agent whose output has field
+func findHandoff(managerSpec *spec.WorkflowSpec, result *PipelineResult, field string) (Handoff, map[string]interface{}, bool) {
+	for _, agentSpec := range managerSpec.Agents {
+		agentResult, ok := result.AllOutputs[agentSpec.ID]
+		if !ok {
+			continue
+		}
+		if _, ok := agentResult.Output[field].(string); !ok {
+			continue
+		}
+
+		workflow, _ := agentResult.Output[fieldRecommendedWorkflow].(string)
+		tier, _ := agentResult.Output[fieldRecommendedTier].(string)
+		rationale, _ := agentResult.Output[fieldRationale].(string)
+		return Handoff{
+			From:      managerSpec.Name,
+			Agent:     agentSpec.ID,
+			Tier:      tier,
+			Workflow:  workflow,
+			Rationale: rationale,
+		}, agentResult.Output, true
+	}
+	return Handoff{}, nil, false
+}
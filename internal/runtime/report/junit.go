@@ -0,0 +1,82 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit renders each gate as a JUnit test case. A pipeline that did not
+// complete is reported as a failing "pipeline" case.
+func JUnit(r *Report) ([]byte, error) {
+	className := strings.ReplaceAll(strings.ToLower(r.Workflow), " ", "_")
+	suite := junitSuite{Name: r.Workflow}
+
+	if r.Status != "" && r.Status != pipeline.StatusCompleted {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "pipeline",
+			ClassName: className,
+			Failure:   &junitFailure{Message: "pipeline " + r.Status, Type: r.Status},
+		})
+	}
+
+	for _, g := range r.Gates {
+		c := junitCase{Name: g.Name, ClassName: className}
+		if !g.Passed {
+			c.Failure = &junitFailure{
+				Message: g.Reason,
+				Type:    "gate",
+				Text:    fmt.Sprintf("%s = %q", g.Field, g.Value),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	suite.Tests = len(suite.Cases)
+	for _, c := range suite.Cases {
+		if c.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	suites := junitSuites{
+		Name:     "multiagency",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitSuite{suite},
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+)
+
+// Markdown renders a PR-comment-ready summary: verdict, gates and a findings table
+func Markdown(r *Report) []byte {
+	var b strings.Builder
+
+	title := r.Title
+	if r.Verdict != "" {
+		title += " — " + r.Verdict
+	}
+	fmt.Fprintf(&b, "## %s\n\n", title)
+
+	if r.Status != "" && r.Status != pipeline.StatusCompleted {
+		fmt.Fprintf(&b, "> ⚠ The pipeline was %s; results are partial.\n\n", r.Status)
+	}
+	if r.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", r.Summary)
+	}
+
+	if len(r.Gates) > 0 {
+		b.WriteString("| Gate | Result | Value |\n")
+		b.WriteString("| ---- | ------ | ----- |\n")
+		for _, g := range r.Gates {
+			result := "✅ pass"
+			if !g.Passed {
+				result = "❌ fail"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(g.Name), result, cell(g.Value))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "### Findings (%d)\n\n", len(r.Findings))
+	if len(r.Findings) == 0 {
+		b.WriteString("No findings.\n\n")
+	} else {
+		b.WriteString("| Severity | Finding | Location |\n")
+		b.WriteString("| -------- | ------- | -------- |\n")
+		for _, f := range r.Findings {
+			finding := cell(f.Message)
+			if f.ID != "" {
+				finding = fmt.Sprintf("**%s** (%s): %s", cell(f.ID), cell(f.Rule), finding)
+			}
+			location := f.Location
+			if f.HasLocation() {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			if location != "" {
+				location = "`" + cell(location) + "`"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(f.Severity), finding, location)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "<sub>%s · %d input / %d output tokens</sub>\n",
+		r.Workflow, r.Tokens.InputTokens, r.Tokens.OutputTokens)
+
+	return []byte(b.String())
+}
+
+// cell keeps a value on one table row
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", "\\|")
+}
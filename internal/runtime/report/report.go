@@ -0,0 +1,285 @@
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// Output formats
+const (
+	FormatJSON     = "json"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists every supported --format value
+var Formats = []string{FormatJSON, FormatSARIF, FormatJUnit, FormatMarkdown}
+
+// Report is a pipeline result reduced to findings and gates
+type Report struct {
+	Workflow string
+	Title    string
+	Status   string
+	Summary  string
+	Verdict  string
+	Findings []Finding
+	Gates    []GateResult
+	Tokens   pipeline.TokenUsage
+}
+
+// Finding is a single issue reported by an agent
+type Finding struct {
+	Agent    string
+	ID       string
+	Rule     string
+	Severity string
+	Level    string // SARIF level
+	Message  string
+	File     string
+	Line     int
+	Column   int
+	Location string // the raw location when it has no file and line
+}
+
+// HasLocation reports whether the finding points at a file and line
+func (f Finding) HasLocation() bool {
+	return f.File != "" && f.Line > 0
+}
+
+// GateResult is the outcome of one gate
+type GateResult struct {
+	Name   string
+	Field  string
+	Value  string
+	Passed bool
+	Reason string
+}
+
+// Failed reports whether any gate failed
+func (r *Report) Failed() bool {
+	for _, g := range r.Gates {
+		if !g.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// Render formats a pipeline result as sarif, junit or markdown. JSON output
+// needs no report section and is written by the caller.
+func Render(format string, workflowSpec *spec.WorkflowSpec, result *pipeline.PipelineResult, toolVersion string) ([]byte, error) {
+	r, err := Build(workflowSpec, result)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatSARIF:
+		return SARIF(r, toolVersion)
+	case FormatJUnit:
+		return JUnit(r)
+	case FormatMarkdown:
+		return Markdown(r), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (use one of: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// defaultLevels maps common severities to SARIF levels
+var defaultLevels = map[string]string{
+	"critical": "error",
+	"high":     "error",
+	"medium":   "warning",
+	"low":      "note",
+	"info":     "note",
+}
+
+// severityRank orders findings from most to least severe; unknown severities sort last
+var severityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "info": 4}
+
+// Build extracts the findings and gates described by the spec's report section
+func Build(workflowSpec *spec.WorkflowSpec, result *pipeline.PipelineResult) (*Report, error) {
+	cfg := workflowSpec.Report
+	if cfg == nil {
+		return nil, fmt.Errorf("spec %q has no report section; add one to use the sarif, junit or markdown formats", workflowSpec.Name)
+	}
+
+	r := &Report{
+		Workflow: workflowSpec.Name,
+		Title:    cfg.Title,
+		Status:   result.Status,
+		Tokens:   result.TokenUsage,
+	}
+	if r.Title == "" {
+		r.Title = workflowSpec.Name
+	}
+	r.Summary = stringValue(lookup(result, cfg.Summary))
+	r.Verdict = stringValue(lookup(result, cfg.Verdict))
+
+	levels := make(map[string]string, len(defaultLevels)+len(cfg.Levels))
+	for k, v := range defaultLevels {
+		levels[k] = v
+	}
+	for k, v := range cfg.Levels {
+		levels[strings.ToLower(k)] = v
+	}
+
+	for _, source := range cfg.Findings {
+		items, _ := lookup(result, source.From).([]interface{})
+		agentID := strings.SplitN(source.From, ".", 2)[0]
+		for _, item := range items {
+			fields, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			r.Findings = append(r.Findings, newFinding(agentID, source, fields, levels))
+		}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		return rank(r.Findings[i].Severity) < rank(r.Findings[j].Severity)
+	})
+
+	for _, gate := range cfg.Gates {
+		r.Gates = append(r.Gates, evaluateGate(gate, result))
+	}
+
+	return r, nil
+}
+
+func newFinding(agentID string, source spec.FindingSource, fields map[string]interface{}, levels map[string]string) Finding {
+	f := Finding{
+		Agent:    agentID,
+		ID:       field(fields, source.ID, "issue_id", "id"),
+		Rule:     field(fields, source.Rule, "category", "type"),
+		Severity: strings.ToLower(field(fields, source.Severity, "severity")),
+		Message:  field(fields, source.Message, "description", "message"),
+	}
+	if f.Rule == "" {
+		f.Rule = source.Category
+	}
+	if f.Rule == "" {
+		f.Rule = agentID
+	}
+
+	f.Level = levels[f.Severity]
+	if f.Level == "" {
+		f.Level = "warning"
+	}
+
+	f.Location = field(fields, source.Location, "location")
+	f.File, f.Line, f.Column = parseLocation(f.Location)
+	if file := field(fields, source.File, "file"); file != "" {
+		f.File = file
+	}
+	if line, err := strconv.Atoi(field(fields, source.Line, "line")); err == nil {
+		f.Line = line
+	}
+
+	return f
+}
+
+func evaluateGate(gate spec.Gate, result *pipeline.PipelineResult) GateResult {
+	g := GateResult{Name: gate.Name, Field: gate.Field}
+
+	value := lookup(result, gate.Field)
+	if value == nil {
+		g.Reason = fmt.Sprintf("%s was not produced", gate.Field)
+		return g
+	}
+	g.Value = stringValue(value)
+
+	switch {
+	case contains(gate.Fail, g.Value):
+		g.Reason = fmt.Sprintf("%s is %q", gate.Field, g.Value)
+	case len(gate.Pass) > 0 && !contains(gate.Pass, g.Value):
+		g.Reason = fmt.Sprintf("%s is %q, expected one of: %s", gate.Field, g.Value, strings.Join(gate.Pass, ", "))
+	default:
+		g.Passed = true
+	}
+	return g
+}
+
+// lookup resolves an agent_id.field.subfield path against the pipeline outputs
+func lookup(result *pipeline.PipelineResult, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, ".")
+	agentResult, ok := result.AllOutputs[parts[0]]
+	if !ok {
+		return nil
+	}
+
+	var value interface{} = agentResult.Output
+	for _, key := range parts[1:] {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// field returns the first non-empty item field among the configured name and the defaults
+func field(fields map[string]interface{}, configured string, defaults ...string) string {
+	names := defaults
+	if configured != "" {
+		names = []string{configured}
+	}
+	for _, name := range names {
+		if s := stringValue(fields[name]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+var locationPattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?`)
+
+// parseLocation splits "path/to/file.go:42:7" into its parts
+func parseLocation(location string) (string, int, int) {
+	m := locationPattern.FindStringSubmatch(strings.TrimSpace(location))
+	if m == nil {
+		return "", 0, 0
+	}
+	line, _ := strconv.Atoi(m[2])
+	column, _ := strconv.Atoi(m[3])
+	return m[1], line, column
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func rank(severity string) int {
+	if r, ok := severityRank[severity]; ok {
+		return r
+	}
+	return len(severityRank)
+}
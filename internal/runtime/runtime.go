@@ -0,0 +1,42 @@
+// Package runtime holds the multiagency workflow engine: spec loading, LLM
+// clients, agent and pipeline execution, report formatting and the local API
+// server. `aiops run` uses it directly; the renderer copies its sources into
+// the standalone multiagency module when a project asks for one.
+package runtime
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+// ImportPrefix is the import path shared by the runtime packages.
+const ImportPrefix = "github.com/voltic-software/aiops/internal/runtime/"
+
+//go:embed jsonschema/*.go spec/*.go llm/*.go agent/*.go pipeline/*.go report/*.go server/*.go memory/*.go
+var sources embed.FS
+
+// Source is a runtime source file as laid out in a generated module.
+type Source struct {
+	Path    string // relative to the module root, e.g. internal/llm/client.go
+	Content []byte
+}
+
+// Sources returns the runtime package sources with their imports rewritten
+// from ImportPrefix to importPrefix, e.g. "github.com/org/project/multiagency/internal/".
+func Sources(importPrefix string) ([]Source, error) {
+	var out []Source
+	err := fs.WalkDir(sources, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		content, err := sources.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		content = []byte(strings.ReplaceAll(string(content), `"`+ImportPrefix, `"`+importPrefix))
+		out = append(out, Source{Path: "internal/" + path, Content: content})
+		return nil
+	})
+	return out, err
+}
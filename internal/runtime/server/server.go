@@ -0,0 +1,394 @@
+// Package server exposes workflow specs and pipeline runs over a local
+// REST/JSON API, so editor extensions and internal tools can start runs
+// without shelling out to the CLI
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/voltic-software/aiops/internal/runtime/llm"
+	"github.com/voltic-software/aiops/internal/runtime/memory"
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+	"github.com/voltic-software/aiops/internal/runtime/spec"
+)
+
+// Options configures a Server
+type Options struct {
+	Addr     string // address the server listens on; requests must name it as Host
+	Token    string // bearer token every request must carry; generated when empty
+	SpecsDir string // directory holding the workflow specs that can be run
+	Provider string // overrides every spec's provider when set
+	Model    string // overrides every spec's model when set
+	NoCache  bool   // disables the response cache
+	KeepRuns int    // finished runs kept in memory; 0 means DefaultKeepRuns
+
+	// Memory is the agent memory store; nil disables memory
+	Memory *memory.Store
+
+	// NewClient creates the LLM client for a run; defaults to pipeline.NewClient
+	NewClient func(llmConfig *spec.LLMConfig, noCache bool) (llm.Client, error)
+}
+
+// Server serves the multiagency API. Every request must carry the session
+// token as "Authorization: Bearer <token>", name the server itself as Host
+// and, from a browser, as Origin, so web pages cannot start runs:
+//
+//	GET  /api/specs               list specs
+//	POST /api/runs                start a run: {"spec", "task", "inputs", "provider", "model"}
+//	GET  /api/runs                list runs, newest first
+//	GET  /api/runs/{id}           run status
+//	GET  /api/runs/{id}/events    server-sent events, replayed from the start of the run
+//	GET  /api/runs/{id}/result    pipeline result of a completed or cancelled run
+//	POST /api/runs/{id}/cancel    cancel a running pipeline
+type Server struct {
+	opts  Options
+	store *Store
+	ctx   context.Context
+	stop  context.CancelFunc
+}
+
+// New creates a server over the specs in opts.SpecsDir
+func New(opts Options) *Server {
+	if opts.NewClient == nil {
+		opts.NewClient = pipeline.NewClient
+	}
+	if opts.Token == "" {
+		opts.Token = newToken()
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Server{opts: opts, store: NewStore(opts.KeepRuns), ctx: ctx, stop: stop}
+}
+
+// Token returns the bearer token clients must send
+func (s *Server) Token() string {
+	return s.opts.Token
+}
+
+// Store returns the server's run store
+func (s *Server) Store() *Store {
+	return s.store
+}
+
+// Close cancels every running pipeline
+func (s *Server) Close() {
+	s.stop()
+}
+
+// Handler returns the HTTP handler for the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/specs", s.listSpecs)
+	mux.HandleFunc("POST /api/runs", s.startRun)
+	mux.HandleFunc("GET /api/runs", s.listRuns)
+	mux.HandleFunc("GET /api/runs/{id}", s.getRun)
+	mux.HandleFunc("GET /api/runs/{id}/events", s.streamEvents)
+	mux.HandleFunc("GET /api/runs/{id}/result", s.getResult)
+	mux.HandleFunc("POST /api/runs/{id}/cancel", s.cancelRun)
+	return s.guard(mux)
+}
+
+// guard rejects requests without the session token, and requests whose Host
+// or Origin is not the server itself, which is how cross-site requests and
+// DNS rebinding from a web page would reach it
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ownHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			host, ok := strings.CutPrefix(origin, "http://")
+			if !ok || !s.ownHost(host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("origin %q is not allowed", origin))
+				return
+			}
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ownHost reports whether host names the server: its listen address, or a
+// loopback name with its port. Names an attacker controls never match.
+func (s *Server) ownHost(host string) bool {
+	if host == s.opts.Addr {
+		return true
+	}
+	name, port, err := net.SplitHostPort(host)
+	if err != nil || !isLoopback(name) {
+		return false
+	}
+	if s.opts.Addr == "" {
+		return true
+	}
+	_, listenPort, err := net.SplitHostPort(s.opts.Addr)
+	return err == nil && port == listenPort
+}
+
+// isLoopback reports whether a host name is localhost or a loopback address
+func isLoopback(name string) bool {
+	if name == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(name, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// newToken returns a random session token
+func newToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SpecInfo describes a spec available to run
+type SpecInfo struct {
+	Name        string   `json:"name"` // file name without extension, used to start runs
+	Workflow    string   `json:"workflow,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Agents      []string `json:"agents,omitempty"`
+	Error       string   `json:"error,omitempty"` // set when the spec fails to load
+}
+
+func (s *Server) listSpecs(w http.ResponseWriter, r *http.Request) {
+	entries, err := os.ReadDir(s.opts.SpecsDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to read specs directory: %w", err))
+		return
+	}
+
+	specs := []SpecInfo{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		info := SpecInfo{Name: strings.TrimSuffix(entry.Name(), ext)}
+		workflowSpec, err := spec.LoadFromFile(filepath.Join(s.opts.SpecsDir, entry.Name()))
+		if err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Workflow = workflowSpec.Name
+			info.Description = workflowSpec.Description
+			for _, a := range workflowSpec.Agents {
+				info.Agents = append(info.Agents, a.ID)
+			}
+		}
+		specs = append(specs, info)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	writeJSON(w, http.StatusOK, specs)
+}
+
+// StartRequest is the body of POST /api/runs
+type StartRequest struct {
+	Spec     string                 `json:"spec"` // spec name as listed by /api/specs
+	Task     string                 `json:"task"`
+	Inputs   map[string]interface{} `json:"inputs,omitempty"` // external context passed to every agent
+	Provider string                 `json:"provider,omitempty"`
+	Model    string                 `json:"model,omitempty"`
+}
+
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("request body must be application/json"))
+		return
+	}
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Task == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("task is required"))
+		return
+	}
+
+	specPath, err := s.specPath(req.Spec)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	workflowSpec, err := spec.LoadFromFile(specPath)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	provider, model := s.opts.Provider, s.opts.Model
+	if req.Provider != "" {
+		provider = req.Provider
+	}
+	if req.Model != "" {
+		model = req.Model
+	}
+	workflowSpec.LLM.Override(provider, model)
+
+	client, err := s.opts.NewClient(&workflowSpec.LLM, s.opts.NoCache)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	executor := pipeline.NewExecutor(workflowSpec, client)
+	executor.SetOutput(io.Discard)
+	executor.SetInputs(req.Inputs)
+	executor.SetMemory(s.opts.Memory)
+
+	id, err := newRunID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	run := newRun(RunStatus{
+		ID:         id,
+		Spec:       req.Spec,
+		Workflow:   workflowSpec.Name,
+		Task:       req.Task,
+		AgentCount: len(workflowSpec.Agents),
+	}, cancel)
+	executor.SetObserver(run.observe)
+	s.store.add(run)
+
+	go func() {
+		result, err := executor.Execute(ctx, req.Task)
+		run.finish(result, err)
+	}()
+
+	writeJSON(w, http.StatusAccepted, run.Status())
+}
+
+// specPath resolves a spec name to a file in the specs directory. Names
+// are plain file names so requests cannot reach outside it.
+func (s *Server) specPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid spec name %q", name)
+	}
+	candidates := []string{name}
+	if filepath.Ext(name) == "" {
+		candidates = []string{name + ".yaml", name + ".yml"}
+	}
+	for _, candidate := range candidates {
+		path := filepath.Join(s.opts.SpecsDir, candidate)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("spec %q not found", name)
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.List())
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.lookup(w, r); run != nil {
+		writeJSON(w, http.StatusOK, run.Status())
+	}
+}
+
+func (s *Server) getResult(w http.ResponseWriter, r *http.Request) {
+	run := s.lookup(w, r)
+	if run == nil {
+		return
+	}
+	result := run.Result()
+	if result == nil {
+		status := run.Status()
+		if status.Status == StatusRunning {
+			writeError(w, http.StatusConflict, fmt.Errorf("run %s is still running", status.ID))
+		} else {
+			writeError(w, http.StatusConflict, fmt.Errorf("run %s failed: %s", status.ID, status.Error))
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.lookup(w, r); run != nil {
+		run.Cancel()
+		writeJSON(w, http.StatusAccepted, run.Status())
+	}
+}
+
+// streamEvents sends the run's events as server-sent events, starting from
+// the first, and ends the stream once the run has finished
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	run := s.lookup(w, r)
+	if run == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+	for {
+		events, changed, finished := run.EventsSince(sent)
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		sent += len(events)
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *Run {
+	id := r.PathValue("id")
+	run := s.store.Get(id)
+	if run == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %q not found", id))
+	}
+	return run
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
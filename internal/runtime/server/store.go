@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voltic-software/aiops/internal/runtime/pipeline"
+)
+
+// Run statuses. Completed and cancelled runs have a result; failed runs
+// only have an error.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = pipeline.StatusCompleted
+	StatusCancelled = pipeline.StatusCancelled
+	StatusFailed    = "failed"
+)
+
+// Run is one pipeline execution started through the API
+type Run struct {
+	mu       sync.Mutex
+	status   RunStatus
+	events   []pipeline.Event
+	changed  chan struct{} // closed and replaced whenever events or status change
+	result   *pipeline.PipelineResult
+	cancel   context.CancelFunc
+	finished bool
+}
+
+// RunStatus is the pollable state of a run
+type RunStatus struct {
+	ID              string     `json:"id"`
+	Spec            string     `json:"spec"`
+	Workflow        string     `json:"workflow"`
+	Task            string     `json:"task"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error,omitempty"`
+	CurrentAgent    string     `json:"current_agent,omitempty"`
+	CompletedAgents int        `json:"completed_agents"`
+	AgentCount      int        `json:"agent_count"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+func newRun(status RunStatus, cancel context.CancelFunc) *Run {
+	status.Status = StatusRunning
+	status.StartedAt = time.Now()
+	return &Run{status: status, changed: make(chan struct{}), cancel: cancel}
+}
+
+// Status returns a snapshot of the run's state
+func (r *Run) Status() RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Result returns the pipeline result once the run has completed or been
+// cancelled, and nil while it is running or after it failed
+func (r *Run) Result() *pipeline.PipelineResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.result
+}
+
+// Cancel stops a running pipeline; it finishes with status cancelled
+func (r *Run) Cancel() {
+	r.cancel()
+}
+
+// EventsSince returns the events after the first n, a channel closed on the
+// next change, and whether the run has finished
+func (r *Run) EventsSince(n int) ([]pipeline.Event, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []pipeline.Event
+	if n < len(r.events) {
+		events = append(events, r.events[n:]...)
+	}
+	return events, r.changed, r.finished
+}
+
+// observe records a pipeline event and updates the status to match
+func (r *Run) observe(event pipeline.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	switch event.Type {
+	case pipeline.EventAgentStarted:
+		r.status.CurrentAgent = event.Agent
+	case pipeline.EventAgentCompleted:
+		r.status.CompletedAgents++
+		r.status.CurrentAgent = ""
+	}
+	r.notify()
+}
+
+// finish records the outcome of the pipeline
+func (r *Run) finish(result *pipeline.PipelineResult, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.status.FinishedAt = &now
+	r.status.CurrentAgent = ""
+	switch {
+	case err != nil:
+		r.status.Status = StatusFailed
+		r.status.Error = err.Error()
+	default:
+		r.result = result
+		r.status.Status = result.Status
+		r.status.Error = result.Error
+	}
+	r.finished = true
+	r.cancel()
+	r.notify()
+}
+
+// notify wakes everyone waiting on changed; callers hold r.mu
+func (r *Run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// DefaultKeepRuns is how many finished runs a store keeps unless told otherwise
+const DefaultKeepRuns = 100
+
+// Store keeps the runs started by a server in memory. Runs are lost when
+// the server exits, and only the most recently finished ones are kept;
+// callers that need results should fetch them first.
+type Store struct {
+	mu   sync.RWMutex
+	runs map[string]*Run
+	keep int
+}
+
+// NewStore creates an empty run store that keeps up to keep finished runs;
+// 0 means DefaultKeepRuns
+func NewStore(keep int) *Store {
+	if keep <= 0 {
+		keep = DefaultKeepRuns
+	}
+	return &Store{runs: make(map[string]*Run), keep: keep}
+}
+
+// Get returns the run with the given ID, or nil
+func (s *Store) Get(id string) *Run {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runs[id]
+}
+
+// List returns the status of every run, newest first
+func (s *Store) List() []RunStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]RunStatus, 0, len(s.runs))
+	for _, run := range s.runs {
+		statuses = append(statuses, run.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartedAt.After(statuses[j].StartedAt)
+	})
+	return statuses
+}
+
+func (s *Store) add(run *Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.status.ID] = run
+	s.prune()
+}
+
+// prune drops the oldest finished runs beyond the limit; running ones are
+// always kept. Callers hold s.mu.
+func (s *Store) prune() {
+	var finished []RunStatus
+	for _, run := range s.runs {
+		if status := run.Status(); status.FinishedAt != nil {
+			finished = append(finished, status)
+		}
+	}
+	if len(finished) <= s.keep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, status := range finished[:len(finished)-s.keep] {
+		delete(s.runs, status.ID)
+	}
+}
+
+// newRunID returns a random 12-character hex ID
+func newRunID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating run ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
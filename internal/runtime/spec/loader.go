@@ -0,0 +1,125 @@
+package spec
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFromFile loads a workflow spec from a YAML file
+func LoadFromFile(path string) (*WorkflowSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	return LoadFromBytes(data)
+}
+
+// LoadFromBytes loads a workflow spec from YAML bytes
+func LoadFromBytes(data []byte) (*WorkflowSpec, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse spec YAML: %w", err)
+	}
+
+	var spec WorkflowSpec
+	if err := root.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec YAML: %w", err)
+	}
+
+	if spec.LLM.MaxTokens == 0 {
+		spec.LLM.MaxTokens = 4096
+	}
+
+	if err := spec.Validate(); err != nil {
+		if vErr, ok := err.(*ValidationError); ok {
+			vErr.Line, vErr.Column = locate(&root, vErr.Field)
+		}
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+
+	return &spec, nil
+}
+
+// locate finds the position of a field path such as "agents[2].input_from[0]"
+// in a YAML document. If the field itself is absent, the position of its
+// closest existing parent is returned so errors still point somewhere useful.
+func locate(root *yaml.Node, path string) (int, int) {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		key, indexes := splitIndexes(part)
+
+		next := mappingValue(node, key)
+		if next == nil {
+			break
+		}
+		node = next
+
+		for _, idx := range indexes {
+			if node.Kind != yaml.SequenceNode || idx >= len(node.Content) {
+				return node.Line, node.Column
+			}
+			node = node.Content[idx]
+		}
+	}
+
+	return node.Line, node.Column
+}
+
+// splitIndexes splits "agents[2][0]" into "agents" and [2, 0].
+func splitIndexes(part string) (string, []int) {
+	open := strings.Index(part, "[")
+	if open == -1 {
+		return part, nil
+	}
+
+	key := part[:open]
+	var indexes []int
+	for _, seg := range strings.Split(part[open:], "[") {
+		seg = strings.TrimSuffix(seg, "]")
+		if n, err := strconv.Atoi(seg); err == nil {
+			indexes = append(indexes, n)
+		}
+	}
+	return key, indexes
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// GetAgentByID returns an agent by its ID
+func (w *WorkflowSpec) GetAgentByID(id string) *Agent {
+	for i := range w.Agents {
+		if w.Agents[i].ID == id {
+			return &w.Agents[i]
+		}
+	}
+	return nil
+}
+
+// GetAgentIndex returns the index of an agent by its ID
+func (w *WorkflowSpec) GetAgentIndex(id string) int {
+	for i := range w.Agents {
+		if w.Agents[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
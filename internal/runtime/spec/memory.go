@@ -0,0 +1,80 @@
+package spec
+
+import "fmt"
+
+// DefaultMemoryEntries is how many entries an agent keeps when max_entries is unset
+const DefaultMemoryEntries = 50
+
+// Memory makes an agent remember output fields across runs, e.g. accepted
+// risks or dismissed findings, so later runs do not raise them again.
+// Entries are stored per spec and agent under .aiops/memory/.
+type Memory struct {
+	Save       []string `yaml:"save,omitempty" jsonschema:"description=Output fields saved after each run, e.g. accepted_risks. Array fields are saved item by item"`
+	Recall     []string `yaml:"recall,omitempty" jsonschema:"description=Agents whose saved memory is added to this agent's prompt. Defaults to this agent when it saves fields"`
+	MaxEntries int      `yaml:"max_entries,omitempty" jsonschema:"description=Entries kept for this agent, most recently seen first. Defaults to 50"`
+}
+
+// RecallOrDefault returns the agents whose memory is recalled, defaulting
+// to the agent itself when it saves fields
+func (m *Memory) RecallOrDefault(agentID string) []string {
+	if m == nil {
+		return nil
+	}
+	if len(m.Recall) == 0 && len(m.Save) > 0 {
+		return []string{agentID}
+	}
+	return m.Recall
+}
+
+// MaxEntriesOrDefault returns the entry limit, defaulting to DefaultMemoryEntries
+func (m *Memory) MaxEntriesOrDefault() int {
+	if m == nil || m.MaxEntries == 0 {
+		return DefaultMemoryEntries
+	}
+	return m.MaxEntries
+}
+
+// validateMemory checks that saved fields exist in each agent's output
+// schema and that recalled agents save something
+func (w *WorkflowSpec) validateMemory() error {
+	for i, agent := range w.Agents {
+		m := agent.Memory
+		if m == nil {
+			continue
+		}
+		field := func(name string) string {
+			return fmt.Sprintf("agents[%d].memory.%s", i, name)
+		}
+
+		if m.MaxEntries < 0 {
+			return &ValidationError{Field: field("max_entries"), Message: "max_entries must not be negative"}
+		}
+		for j, name := range m.Save {
+			if _, ok := agent.OutputSchema.Properties[name]; !ok {
+				return &ValidationError{
+					Field:   fmt.Sprintf("%s[%d]", field("save"), j),
+					Message: fmt.Sprintf("agent '%s' has no output field '%s'", agent.ID, name),
+				}
+			}
+		}
+		for j, id := range m.Recall {
+			source := w.GetAgentByID(id)
+			if source == nil {
+				return &ValidationError{
+					Field:   fmt.Sprintf("%s[%d]", field("recall"), j),
+					Message: fmt.Sprintf("agent '%s' recalls unknown agent '%s'", agent.ID, id),
+				}
+			}
+			if source.Memory == nil || len(source.Memory.Save) == 0 {
+				return &ValidationError{
+					Field:   fmt.Sprintf("%s[%d]", field("recall"), j),
+					Message: fmt.Sprintf("agent '%s' recalls agent '%s', which saves no memory", agent.ID, id),
+				}
+			}
+		}
+		if len(m.Save) == 0 && len(m.Recall) == 0 {
+			return &ValidationError{Field: field("save"), Message: "memory needs save or recall"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report maps agent outputs onto findings and pass/fail gates for the sarif,
+// junit and markdown formats of `aiops run` and `multiagency run`. Paths are
+// written as agent_id.field, e.g. security_reviewer.vulnerabilities.
+type Report struct {
+	Title    string            `yaml:"title,omitempty" jsonschema:"description=Heading of the markdown summary. Defaults to the spec name"`
+	Summary  string            `yaml:"summary,omitempty" jsonschema:"description=Path to the summary text, e.g. summarizer.summary"`
+	Verdict  string            `yaml:"verdict,omitempty" jsonschema:"description=Path to the overall verdict, e.g. summarizer.overall_rating"`
+	Findings []FindingSource   `yaml:"findings,omitempty"`
+	Gates    []Gate            `yaml:"gates,omitempty"`
+	Levels   map[string]string `yaml:"levels,omitempty" jsonschema:"description=Severity to SARIF level (error, warning, note, none). Merged over the defaults for critical/high/medium/low/info"`
+}
+
+// FindingSource reads findings from an array in an agent's output. The item
+// field names are optional and default to the names code_review.yaml uses.
+type FindingSource struct {
+	From     string `yaml:"from" jsonschema:"required,description=Path to an array of findings, e.g. security_reviewer.vulnerabilities"`
+	Category string `yaml:"category,omitempty" jsonschema:"description=Category for every finding from this source when items have no rule field"`
+	ID       string `yaml:"id,omitempty" jsonschema:"description=Item field with the finding ID. Defaults to issue_id"`
+	Rule     string `yaml:"rule,omitempty" jsonschema:"description=Item field used as the SARIF rule. Defaults to category, then type"`
+	Severity string `yaml:"severity,omitempty" jsonschema:"description=Item field with the severity. Defaults to severity"`
+	Message  string `yaml:"message,omitempty" jsonschema:"description=Item field with the description. Defaults to description"`
+	Location string `yaml:"location,omitempty" jsonschema:"description=Item field with a path:line[:column] location. Defaults to location"`
+	File     string `yaml:"file,omitempty" jsonschema:"description=Item field with the file path, when not part of location"`
+	Line     string `yaml:"line,omitempty" jsonschema:"description=Item field with the line number, when not part of location"`
+}
+
+// Gate is a pass/fail check on a single output value, reported as a JUnit test case
+type Gate struct {
+	Name  string   `yaml:"name" jsonschema:"required"`
+	Field string   `yaml:"field" jsonschema:"required,description=Path to the value checked, e.g. security_reviewer.security_score"`
+	Fail  []string `yaml:"fail,omitempty" jsonschema:"description=Values that fail the gate"`
+	Pass  []string `yaml:"pass,omitempty" jsonschema:"description=Values that pass the gate; anything else fails"`
+}
+
+// SARIF levels
+var sarifLevels = map[string]bool{"error": true, "warning": true, "note": true, "none": true}
+
+// Validate checks that report paths point at agents in the spec
+func (r *Report) Validate(agentIDs map[string]int) error {
+	checkPath := func(field, path string) error {
+		agentID := strings.SplitN(path, ".", 2)[0]
+		if _, ok := agentIDs[agentID]; !ok || !strings.Contains(path, ".") {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("%q must be agent_id.field with a known agent", path)}
+		}
+		return nil
+	}
+
+	if r.Summary != "" {
+		if err := checkPath("report.summary", r.Summary); err != nil {
+			return err
+		}
+	}
+	if r.Verdict != "" {
+		if err := checkPath("report.verdict", r.Verdict); err != nil {
+			return err
+		}
+	}
+
+	for i, f := range r.Findings {
+		if err := checkPath(fmt.Sprintf("report.findings[%d].from", i), f.From); err != nil {
+			return err
+		}
+	}
+
+	for i, g := range r.Gates {
+		if g.Name == "" {
+			return &ValidationError{Field: fmt.Sprintf("report.gates[%d].name", i), Message: "gate name is required"}
+		}
+		if err := checkPath(fmt.Sprintf("report.gates[%d].field", i), g.Field); err != nil {
+			return err
+		}
+		if len(g.Fail) == 0 && len(g.Pass) == 0 {
+			return &ValidationError{Field: fmt.Sprintf("report.gates[%d]", i), Message: "gate needs fail or pass values"}
+		}
+	}
+
+	for severity, level := range r.Levels {
+		if !sarifLevels[level] {
+			return &ValidationError{
+				Field:   "report.levels." + severity,
+				Message: "level must be one of: error, warning, note, none",
+			}
+		}
+	}
+
+	return nil
+}
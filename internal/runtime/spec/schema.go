@@ -0,0 +1,17 @@
+package spec
+
+import (
+	"github.com/voltic-software/aiops/internal/runtime/jsonschema"
+)
+
+// SchemaID is the $id of the workflow spec JSON Schema
+const SchemaID = "https://github.com/voltic-software/aiops/workflow.schema.json"
+
+// JSONSchema returns the JSON Schema for workflow specs, generated from WorkflowSpec.
+// Point editors at it with a `# yaml-language-server: $schema=workflow.schema.json` header.
+func JSONSchema() ([]byte, error) {
+	s := jsonschema.Generate(WorkflowSpec{}, SchemaID, "multiagency workflow spec")
+	// Specs may carry extra top-level documentation keys (e.g. risks.yaml's pipeline)
+	s["additionalProperties"] = true
+	return jsonschema.Marshal(s)
+}
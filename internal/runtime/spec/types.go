@@ -0,0 +1,393 @@
+package spec
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowSpec defines a complete multi-agent workflow
+type WorkflowSpec struct {
+	Version     string    `yaml:"version" jsonschema:"required"`
+	Name        string    `yaml:"name" jsonschema:"required"`
+	Description string    `yaml:"description"`
+	LLM         LLMConfig `yaml:"llm" jsonschema:"required"`
+	Agents      []Agent   `yaml:"agents" jsonschema:"required"`
+	Timeout     Duration  `yaml:"timeout,omitempty" jsonschema:"description=Cancel the pipeline after this long, keeping completed outputs, e.g. 10m"`
+	Report      *Report   `yaml:"report,omitempty"`
+}
+
+// LLMConfig defines the LLM provider configuration
+type LLMConfig struct {
+	Provider    string  `yaml:"provider" jsonschema:"required,enum=anthropic|ollama|stub|cascade"`
+	Model       string  `yaml:"model" jsonschema:"required"`
+	Temperature float64 `yaml:"temperature" jsonschema:"description=Sampling temperature between 0 and 1"`
+	MaxTokens   int     `yaml:"max_tokens" jsonschema:"description=Defaults to 4096"`
+	Cache       *Cache  `yaml:"cache,omitempty"`
+
+	// PromptCaching marks the system prompt and shared context (task and
+	// upstream outputs) as cacheable on providers that support it
+	PromptCaching bool `yaml:"prompt_caching,omitempty"`
+	// OutputMode "tool" asks the provider for structured output by declaring
+	// the agent's output_schema as a forced tool input schema
+	OutputMode string `yaml:"output_mode,omitempty" jsonschema:"enum=text|tool,description=Defaults to text"`
+
+	// Fallback lists providers tried in order when the primary fails with a
+	// non-retryable or quota error
+	Fallback  []Fallback `yaml:"fallback,omitempty"`
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty"`
+}
+
+// Fallback is a provider and model to switch to when the ones before it fail
+type Fallback struct {
+	Provider string `yaml:"provider" jsonschema:"required,enum=anthropic|ollama|stub"`
+	Model    string `yaml:"model" jsonschema:"required"`
+}
+
+// RateLimit caps LLM traffic for the whole process; parallel agents and
+// samples, and runs of specs with the same limits, share the same budget.
+// Zero means unlimited.
+type RateLimit struct {
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `yaml:"tokens_per_minute,omitempty" jsonschema:"description=Input plus output tokens"`
+}
+
+// Output modes
+const (
+	OutputModeText = "text"
+	OutputModeTool = "tool"
+)
+
+// Cache configures the on-disk response cache. It is on by default for
+// temperature 0 and can be turned off per run with --no-cache.
+type Cache struct {
+	Disabled        bool     `yaml:"disabled,omitempty"`
+	Dir             string   `yaml:"dir,omitempty" jsonschema:"description=Defaults to .multiagency/cache"`
+	TTL             Duration `yaml:"ttl,omitempty" jsonschema:"description=How long entries stay valid, e.g. 24h. Defaults to no expiry"`
+	MaxSizeMB       int      `yaml:"max_size_mb,omitempty" jsonschema:"description=Oldest entries are evicted above this size. Defaults to 100"`
+	AllTemperatures bool     `yaml:"all_temperatures,omitempty" jsonschema:"description=Also cache requests with temperature > 0"`
+}
+
+// Duration is a time.Duration written as a Go duration string ("90s", "5m")
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q (use e.g. 90s, 5m, 24h)", node.Line, node.Value)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalYAML writes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// JSONSchema describes Duration as a duration string
+func (Duration) JSONSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "string",
+		"pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$",
+	}
+}
+
+// Agent defines a single agent in the workflow
+type Agent struct {
+	ID           string       `yaml:"id" jsonschema:"required"`
+	Role         string       `yaml:"role" jsonschema:"required"`
+	Goal         string       `yaml:"goal" jsonschema:"required"`
+	Constraints  []string     `yaml:"constraints"`
+	InputFrom    []string     `yaml:"input_from" jsonschema:"description=IDs of earlier agents whose output is passed as context"`
+	OutputSchema OutputSchema `yaml:"output_schema"`
+	MCPTools     []string     `yaml:"mcp_tools"`
+	Samples      int          `yaml:"samples,omitempty" jsonschema:"description=Run the agent N times concurrently and aggregate the outputs"`
+	Aggregation  *Aggregation `yaml:"aggregation,omitempty"`
+	Timeout      Duration     `yaml:"timeout,omitempty" jsonschema:"description=Fail the agent if it runs longer than this, including retries, e.g. 2m"`
+	Memory       *Memory      `yaml:"memory,omitempty"`
+}
+
+// Aggregation strategies for multi-sample agents
+const (
+	AggregateVote  = "vote"  // majority vote on enum/boolean fields, merge arrays
+	AggregateJudge = "judge" // a judge agent merges the samples
+
+	ArraysUnion        = "union"
+	ArraysIntersection = "intersection"
+)
+
+// Aggregation configures how the samples of a multi-sample agent are merged
+type Aggregation struct {
+	Strategy string `yaml:"strategy" jsonschema:"enum=vote|judge,description=Defaults to vote"`
+	Arrays   string `yaml:"arrays" jsonschema:"enum=union|intersection,description=How array fields are merged when voting. Defaults to union"`
+	Judge    *Judge `yaml:"judge,omitempty"`
+}
+
+// Judge defines the agent that merges samples when the strategy is judge
+type Judge struct {
+	Role        string   `yaml:"role"`
+	Goal        string   `yaml:"goal"`
+	Constraints []string `yaml:"constraints"`
+}
+
+// StrategyOrDefault returns the aggregation strategy, defaulting to vote
+func (a *Aggregation) StrategyOrDefault() string {
+	if a == nil || a.Strategy == "" {
+		return AggregateVote
+	}
+	return a.Strategy
+}
+
+// ArraysOrDefault returns the array merge mode, defaulting to union
+func (a *Aggregation) ArraysOrDefault() string {
+	if a == nil || a.Arrays == "" {
+		return ArraysUnion
+	}
+	return a.Arrays
+}
+
+// OutputSchema defines the expected JSON output structure
+type OutputSchema struct {
+	Type       string                 `yaml:"type" jsonschema:"enum=object|array|string|number|integer|boolean"`
+	Properties map[string]SchemaField `yaml:"properties"`
+	Required   []string               `yaml:"required"`
+	Items      *SchemaField           `yaml:"items"`
+}
+
+// SchemaField defines a single field in the output schema
+type SchemaField struct {
+	Type        string                 `yaml:"type" jsonschema:"enum=object|array|string|number|integer|boolean"`
+	Description string                 `yaml:"description"`
+	Properties  map[string]SchemaField `yaml:"properties"`
+	Items       *SchemaField           `yaml:"items"`
+	Enum        []string               `yaml:"enum"`
+}
+
+// ToJSONSchema converts the output schema to a JSON Schema object
+func (s *OutputSchema) ToJSONSchema() map[string]interface{} {
+	out := map[string]interface{}{}
+	if s.Type != "" {
+		out["type"] = s.Type
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]interface{}, len(s.Properties))
+		for name, field := range s.Properties {
+			props[name] = field.ToJSONSchema()
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	if s.Items != nil {
+		out["items"] = s.Items.ToJSONSchema()
+	}
+	return out
+}
+
+// ToJSONSchema converts the field to a JSON Schema object
+func (f *SchemaField) ToJSONSchema() map[string]interface{} {
+	out := map[string]interface{}{}
+	if f.Type != "" {
+		out["type"] = f.Type
+	}
+	if f.Description != "" {
+		out["description"] = f.Description
+	}
+	if len(f.Enum) > 0 {
+		out["enum"] = f.Enum
+	}
+	if len(f.Properties) > 0 {
+		props := make(map[string]interface{}, len(f.Properties))
+		for name, sub := range f.Properties {
+			props[name] = sub.ToJSONSchema()
+		}
+		out["properties"] = props
+	}
+	if f.Items != nil {
+		out["items"] = f.Items.ToJSONSchema()
+	}
+	return out
+}
+
+// Validate checks if the workflow spec is valid
+func (w *WorkflowSpec) Validate() error {
+	if w.Version == "" {
+		return &ValidationError{Field: "version", Message: "version is required"}
+	}
+	if w.Name == "" {
+		return &ValidationError{Field: "name", Message: "name is required"}
+	}
+	if len(w.Agents) == 0 {
+		return &ValidationError{Field: "agents", Message: "at least one agent is required"}
+	}
+
+	if err := w.LLM.Validate(); err != nil {
+		return err
+	}
+	if w.Timeout.Duration < 0 {
+		return &ValidationError{Field: "timeout", Message: "timeout must not be negative"}
+	}
+
+	agentIDs := make(map[string]int)
+	for i, agent := range w.Agents {
+		agentIDs[agent.ID] = i
+	}
+
+	for i, agent := range w.Agents {
+		if err := agent.Validate(i, agentIDs); err != nil {
+			return err
+		}
+		if w.LLM.OutputMode == OutputModeTool && agent.OutputSchema.Type != "" && agent.OutputSchema.Type != "object" {
+			return &ValidationError{
+				Field:   fmt.Sprintf("agents[%d].output_schema.type", i),
+				Message: "output_mode tool requires an object output_schema",
+			}
+		}
+	}
+
+	if err := w.validateMemory(); err != nil {
+		return err
+	}
+
+	if w.Report != nil {
+		if err := w.Report.Validate(agentIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks if the LLM config is valid
+func (l *LLMConfig) Validate() error {
+	if l.Provider == "" {
+		return &ValidationError{Field: "llm.provider", Message: "provider is required"}
+	}
+	validProviders := map[string]bool{"anthropic": true, "ollama": true, "stub": true, "cascade": true}
+	if !validProviders[l.Provider] {
+		return &ValidationError{Field: "llm.provider", Message: "provider must be one of: anthropic, ollama, stub, cascade"}
+	}
+	if l.Model == "" {
+		return &ValidationError{Field: "llm.model", Message: "model is required"}
+	}
+	if l.Temperature < 0 || l.Temperature > 1 {
+		return &ValidationError{Field: "llm.temperature", Message: "temperature must be between 0 and 1"}
+	}
+	if l.MaxTokens <= 0 {
+		l.MaxTokens = 4096
+	}
+	switch l.OutputMode {
+	case "", OutputModeText, OutputModeTool:
+	default:
+		return &ValidationError{Field: "llm.output_mode", Message: "output_mode must be one of: text, tool"}
+	}
+	if l.Cache != nil && l.Cache.MaxSizeMB < 0 {
+		return &ValidationError{Field: "llm.cache.max_size_mb", Message: "max_size_mb must not be negative"}
+	}
+	for i, fb := range l.Fallback {
+		switch fb.Provider {
+		case "anthropic", "ollama", "stub":
+		default:
+			return &ValidationError{
+				Field:   fmt.Sprintf("llm.fallback[%d].provider", i),
+				Message: "fallback provider must be one of: anthropic, ollama, stub",
+			}
+		}
+		if fb.Model == "" {
+			return &ValidationError{Field: fmt.Sprintf("llm.fallback[%d].model", i), Message: "model is required"}
+		}
+	}
+	if l.RateLimit != nil && (l.RateLimit.RequestsPerMinute < 0 || l.RateLimit.TokensPerMinute < 0) {
+		return &ValidationError{Field: "llm.rate_limit", Message: "rate limits must not be negative"}
+	}
+	return nil
+}
+
+// Override replaces the provider and model when non-empty, e.g. to run a
+// cascade spec from the CLI against a real API. An explicit provider also
+// drops the fallback chain.
+func (l *LLMConfig) Override(provider, model string) {
+	if provider != "" {
+		l.Provider = provider
+		l.Fallback = nil
+	}
+	if model != "" {
+		l.Model = model
+	}
+}
+
+// Validate checks if the agent definition is valid
+func (a *Agent) Validate(index int, agentIDs map[string]int) error {
+	field := func(name string) string {
+		return fmt.Sprintf("agents[%d].%s", index, name)
+	}
+
+	if a.ID == "" {
+		return &ValidationError{Field: field("id"), Message: "agent id is required"}
+	}
+	if a.Role == "" {
+		return &ValidationError{Field: field("role"), Message: "agent role is required"}
+	}
+	if a.Goal == "" {
+		return &ValidationError{Field: field("goal"), Message: "agent goal is required"}
+	}
+
+	if a.Timeout.Duration < 0 {
+		return &ValidationError{Field: field("timeout"), Message: "timeout must not be negative"}
+	}
+	if a.Samples < 0 || a.Samples > 10 {
+		return &ValidationError{Field: field("samples"), Message: "samples must be between 0 and 10"}
+	}
+	if a.Aggregation != nil {
+		switch a.Aggregation.StrategyOrDefault() {
+		case AggregateVote, AggregateJudge:
+		default:
+			return &ValidationError{Field: field("aggregation.strategy"), Message: "strategy must be one of: vote, judge"}
+		}
+		switch a.Aggregation.ArraysOrDefault() {
+		case ArraysUnion, ArraysIntersection:
+		default:
+			return &ValidationError{Field: field("aggregation.arrays"), Message: "arrays must be one of: union, intersection"}
+		}
+	}
+
+	for i, inputID := range a.InputFrom {
+		refIndex, exists := agentIDs[inputID]
+		if !exists {
+			return &ValidationError{
+				Field:   fmt.Sprintf("agents[%d].input_from[%d]", index, i),
+				Message: "agent '" + a.ID + "' references unknown agent '" + inputID + "'",
+			}
+		}
+		if refIndex >= index {
+			return &ValidationError{
+				Field:   fmt.Sprintf("agents[%d].input_from[%d]", index, i),
+				Message: "agent '" + a.ID + "' cannot reference agent '" + inputID + "' that comes after it",
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidationError represents a spec validation error. Line and Column are
+// filled in by the loader when the failing field can be located in the YAML.
+type ValidationError struct {
+	Field   string
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("validation error in %s (line %d, column %d): %s", e.Field, e.Line, e.Column, e.Message)
+	}
+	return "validation error in " + e.Field + ": " + e.Message
+}
@@ -14,6 +14,7 @@ type Target struct {
 	OrchestrDir   string // Where orchestrator state goes (relative to project dir)
 	SkillsDir     string // Where skill scaffolds go (relative to project dir)
 	RulesFormat   string // "markdown", "yaml", "mdc"
+	ManagedRules  bool   // RepoRulesPath is often hand-written too; aiops only manages a block in it
 }
 
 // All known targets.
@@ -62,6 +63,7 @@ var Copilot = Target{
 	OrchestrDir:   "", // No orchestrator support
 	SkillsDir:     "", // No skills support
 	RulesFormat:   "markdown",
+	ManagedRules:  true,
 }
 
 // ResolveRepoRulesPath returns the absolute path for the repo rules file.
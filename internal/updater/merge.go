@@ -0,0 +1,157 @@
+package updater
+
+import (
+	"slices"
+	"strings"
+)
+
+// Conflict strategies for Merge and Plan.Resolve. The default writes
+// conflict markers.
+const (
+	StrategyMarkers = ""
+	StrategyOurs    = "ours"   // keep the local side of each conflict
+	StrategyTheirs  = "theirs" // take the template side of each conflict
+)
+
+// Conflict markers written around the sides of an unresolved hunk.
+const (
+	markerLocal = "<<<<<<< local"
+	markerBase  = "||||||| base"
+	markerSep   = "======="
+	markerAiops = ">>>>>>> aiops"
+)
+
+// ValidStrategy reports whether s names a conflict strategy.
+func ValidStrategy(s string) bool {
+	return s == StrategyMarkers || s == StrategyOurs || s == StrategyTheirs
+}
+
+// Merge combines the local edits and the template changes made since base,
+// line by line. Hunks changed on one side only take that side; hunks both
+// sides changed differently are conflicts, resolved by strategy. It returns
+// the merged content and the number of conflicting hunks.
+func Merge(base, local, upstream []byte, strategy string) ([]byte, int) {
+	b, l, u := splitLines(base), splitLines(local), splitLines(upstream)
+	toLocal, toUpstream := matches(b, l), matches(b, u)
+
+	var out []string
+	conflicts := 0
+	i, j, k := 0, 0, 0
+	for {
+		// The next base line kept unchanged on both sides ends the current hunk
+		m := i
+		for m < len(b) && (toLocal[m] < 0 || toUpstream[m] < 0) {
+			m++
+		}
+		lEnd, uEnd := len(l), len(u)
+		if m < len(b) {
+			lEnd, uEnd = toLocal[m], toUpstream[m]
+		}
+
+		if m > i || lEnd > j || uEnd > k {
+			var conflict bool
+			out, conflict = mergeHunk(out, b[i:m], l[j:lEnd], u[k:uEnd], strategy)
+			if conflict {
+				conflicts++
+			}
+		}
+		if m == len(b) {
+			break
+		}
+		out = append(out, b[m])
+		i, j, k = m+1, lEnd+1, uEnd+1
+	}
+
+	if len(out) == 0 {
+		return []byte{}, conflicts
+	}
+	return []byte(strings.Join(out, "\n") + "\n"), conflicts
+}
+
+// mergeHunk appends the merge of one hunk and reports whether it conflicted.
+func mergeHunk(out, base, local, upstream []string, strategy string) ([]string, bool) {
+	switch {
+	case slices.Equal(local, base):
+		return append(out, upstream...), false
+	case slices.Equal(upstream, base), slices.Equal(local, upstream):
+		return append(out, local...), false
+	}
+
+	switch strategy {
+	case StrategyOurs:
+		out = append(out, local...)
+	case StrategyTheirs:
+		out = append(out, upstream...)
+	default:
+		out = append(out, markerLocal)
+		out = append(out, local...)
+		out = append(out, markerBase)
+		out = append(out, base...)
+		out = append(out, markerSep)
+		out = append(out, upstream...)
+		out = append(out, markerAiops)
+	}
+	return out, true
+}
+
+// ResolveMarkers replaces the conflict blocks Merge wrote into content with
+// one side, local for StrategyOurs and the template's for StrategyTheirs;
+// StrategyMarkers leaves content as is. It returns the content and the
+// number of conflict blocks found. Unterminated blocks are left alone.
+func ResolveMarkers(content []byte, strategy string) ([]byte, int) {
+	lines := splitLines(content)
+	var out []string
+	blocks := 0
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != markerLocal {
+			out = append(out, lines[i])
+			continue
+		}
+		base := slices.Index(lines[i:], markerBase)
+		sep := slices.Index(lines[i:], markerSep)
+		end := slices.Index(lines[i:], markerAiops)
+		if base < 0 || sep < base || end < sep {
+			out = append(out, lines[i])
+			continue
+		}
+		base, sep, end = i+base, i+sep, i+end
+		blocks++
+		switch strategy {
+		case StrategyOurs:
+			out = append(out, lines[i+1:base]...)
+		case StrategyTheirs:
+			out = append(out, lines[sep+1:end]...)
+		default:
+			out = append(out, lines[i:end+1]...)
+		}
+		i = end
+	}
+	if blocks == 0 || strategy == StrategyMarkers {
+		return content, blocks
+	}
+	if len(out) == 0 {
+		return []byte{}, blocks
+	}
+	return []byte(strings.Join(out, "\n") + "\n"), blocks
+}
+
+// matches maps each line of a to the index of the same line in b along
+// their longest common subsequence, or -1 when it was removed.
+func matches(a, b []string) []int {
+	m := make([]int, len(a))
+	i, j := 0, 0
+	for _, op := range lineEdits(a, b) {
+		switch op.kind {
+		case ' ':
+			m[i] = j
+			i++
+			j++
+		case '-':
+			m[i] = -1
+			i++
+		case '+':
+			j++
+		}
+	}
+	return m
+}
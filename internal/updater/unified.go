@@ -0,0 +1,181 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown around each change.
+const DiffContext = 3
+
+// Stat counts the lines a diff adds and removes.
+type Stat struct {
+	Added   int
+	Removed int
+}
+
+// editOp is one line of a line-based edit script.
+type editOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Stat returns the number of added and removed lines.
+func (d Diff) Stat() Stat {
+	var s Stat
+	for _, op := range lineEdits(splitLines(d.Current), splitLines(d.New)) {
+		switch op.kind {
+		case '+':
+			s.Added++
+		case '-':
+			s.Removed++
+		}
+	}
+	return s
+}
+
+// Unified returns the diff from the installed to the freshly rendered
+// content in unified format, or "" when they are equal.
+func (d Diff) Unified(context int) string {
+	ops := lineEdits(splitLines(d.Current), splitLines(d.New))
+
+	var changed []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	from := "a/" + d.Path
+	if d.Current == nil {
+		from = "/dev/null"
+	}
+	to := "b/" + d.Path
+	if d.New == nil {
+		to = "/dev/null"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+
+	// Group changes whose context overlaps into hunks
+	for i := 0; i < len(changed); {
+		start := max(changed[i]-context, 0)
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*context {
+			j++
+		}
+		end := min(changed[j]+context+1, len(ops))
+		writeHunk(&b, ops, start, end)
+		i = j + 1
+	}
+	return b.String()
+}
+
+// writeHunk writes ops[start:end] as one hunk with its @@ header.
+func writeHunk(b *strings.Builder, ops []editOp, start, end int) {
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+
+	var oldCount, newCount int
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	// An empty side is addressed by the line before it
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, op := range ops[start:end] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		b.WriteByte('\n')
+	}
+}
+
+// splitLines splits content into lines without their newline.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// lineEdits returns a shortest edit script turning a into b, from the
+// longest common subsequence of their lines. The common prefix and suffix
+// are trimmed first, so typical template updates stay cheap.
+func lineEdits(a, b []string) []editOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]editOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, editOp{' ', line})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			ops = append(ops, editOp{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, editOp{'-', x[i]})
+			i++
+		default:
+			ops = append(ops, editOp{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		ops = append(ops, editOp{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		ops = append(ops, editOp{'+', y[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, editOp{' ', line})
+	}
+	return ops
+}
@@ -1,7 +1,9 @@
 package updater
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -15,96 +17,267 @@ import (
 // Diff represents a single file difference between current and updated.
 type Diff struct {
 	Path        string
-	Status      string // "new", "modified", "unchanged"
+	Status      string // "new", "modified", "merged", "conflict", "unchanged", "removed"
+	Ownership   string // renderer.OwnerAiops, OwnerSeedOnce or OwnerUser
 	CurrentHash string
 	NewHash     string
+	Current     []byte // installed content, nil for new files
+	New         []byte // content to write: rendered, or merged with local edits; nil for removed files
+	Base        []byte // content aiops last rendered, set for merged and conflicting files
+	Conflicts   int    // conflicting hunks in a merge
+
+	file renderer.File // the rendered file, recorded in the manifest on Apply
 }
 
 // Plan represents the update plan showing what would change.
 type Plan struct {
-	Diffs     []Diff
-	NewFiles  int
-	Modified  int
-	Unchanged int
+	Diffs      []Diff
+	NewFiles   int
+	Modified   int
+	Merged     int // locally edited files whose edits are kept
+	Conflicted int // locally edited files with conflicting hunks
+	Removed    int // obsolete aiops-owned files the templates no longer produce
+	Unchanged  int
+	Skipped    []renderer.Skip // seeds the project already has, left to the team
 }
 
 // ComputePlan compares current installed files against what templates would generate.
+// Seed-once and user-owned files that already exist belong to the team; the
+// render skips them and they are listed in Skipped. Aiops-owned files in the manifest that are no longer
+// rendered are planned for removal. Files edited since the last render
+// are merged with the template changes, conflicts marked.
 func ComputePlan(projectDir string, cfg *config.ProjectConfig) (*Plan, error) {
-	// Render to temp dir using same relative paths as real project
-	tmpDir, err := os.MkdirTemp("", "aiops-update-*")
+	// Rendering in memory against the real project keeps its skip decisions
+	// and managed blocks
+	out, err := renderer.RenderFiles(projectDir, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("creating temp dir: %w", err)
+		return nil, fmt.Errorf("rendering templates: %w", err)
 	}
-	defer os.RemoveAll(tmpDir)
-
-	// Use same relative paths so rendered list matches real structure
-	tmpCfg := *cfg
-
-	// Render templates to temp dir
-	rendered, err := renderer.RenderAll(tmpDir, &tmpCfg)
+	files := out.Files
+	manifest, err := renderer.LoadManifest(projectDir)
 	if err != nil {
-		return nil, fmt.Errorf("rendering templates: %w", err)
+		return nil, err
 	}
 
-	plan := &Plan{}
+	plan := &Plan{Skipped: out.Skipped}
+	rendered := make(map[string]bool, len(files))
 
-	for _, relPath := range rendered {
-		tmpPath := filepath.Join(tmpDir, relPath)
+	for _, f := range files {
+		rendered[f.Path] = true
 
 		// Determine real output path
-		realPath := resolveRealPath(projectDir, cfg, relPath)
+		realPath := resolveRealPath(projectDir, cfg, f.Path)
 
 		// Use a display path that's project-relative
-		displayPath := relPath
-		if strings.HasPrefix(relPath, "../") || strings.HasPrefix(relPath, "/") {
+		displayPath := f.Path
+		if strings.HasPrefix(f.Path, "../") || strings.HasPrefix(f.Path, "/") {
 			// Memory paths may be outside project dir
 			displayPath = realPath
 		}
 
-		newHash := hashFile(tmpPath)
-		currentHash := hashFile(realPath)
+		currentContent, err := os.ReadFile(realPath)
+		if err != nil {
+			currentContent = nil
+		}
 
 		diff := Diff{
 			Path:        displayPath,
-			CurrentHash: currentHash,
-			NewHash:     newHash,
+			Ownership:   f.Ownership,
+			CurrentHash: hashContent(currentContent),
+			Current:     currentContent,
+			New:         f.Content,
+			file:        f,
 		}
 
-		if currentHash == "" {
+		// Keep local edits made since the last render. A managed file whose
+		// block was taken out gets a fresh one instead.
+		if entry := manifest.Lookup(f.Path); entry != nil && currentContent != nil && !bytes.Equal(currentContent, f.Content) && !missingBlock(f, currentContent) {
+			if base := entry.ReadBase(projectDir); base != nil && !bytes.Equal(currentContent, base) {
+				diff.Base = base
+				diff.New, diff.Conflicts = Merge(base, currentContent, f.Content, StrategyMarkers)
+			}
+		}
+		// Markers written by an earlier update stay a conflict until they
+		// are resolved, by hand or with a strategy
+		if _, unresolved := ResolveMarkers(diff.New, StrategyMarkers); unresolved > diff.Conflicts && diff.Base != nil {
+			diff.Conflicts = unresolved
+		}
+		diff.NewHash = hashContent(diff.New)
+
+		switch {
+		case diff.CurrentHash == "":
 			diff.Status = "new"
 			plan.NewFiles++
-		} else if currentHash != newHash {
-			diff.Status = "modified"
-			plan.Modified++
-		} else {
+		case diff.Conflicts > 0:
+			diff.Status = "conflict"
+			plan.Conflicted++
+		case diff.CurrentHash == diff.NewHash:
 			diff.Status = "unchanged"
 			plan.Unchanged++
+		case diff.Base != nil:
+			diff.Status = "merged"
+			plan.Merged++
+		default:
+			diff.Status = "modified"
+			plan.Modified++
 		}
 
 		plan.Diffs = append(plan.Diffs, diff)
 	}
 
+	for _, entry := range manifest.Files {
+		if rendered[entry.Path] || entry.Ownership != renderer.OwnerAiops {
+			continue
+		}
+		currentContent, err := os.ReadFile(resolveRealPath(projectDir, cfg, entry.Path))
+		if err != nil {
+			continue
+		}
+		plan.Diffs = append(plan.Diffs, Diff{
+			Path:        entry.Path,
+			Status:      "removed",
+			Ownership:   entry.Ownership,
+			CurrentHash: hashContent(currentContent),
+			Current:     currentContent,
+			file:        renderer.File{Path: entry.Path},
+		})
+		plan.Removed++
+	}
+
 	return plan, nil
 }
 
-// Apply writes updated files for the given diffs.
-func Apply(projectDir string, cfg *config.ProjectConfig, plan *Plan, includeUnchanged bool) ([]string, error) {
-	// Render fresh templates
-	files, err := renderer.RenderAll(projectDir, cfg)
-	if err != nil {
-		return nil, fmt.Errorf("rendering templates: %w", err)
+// missingBlock reports whether a managed file has lost its block.
+func missingBlock(f renderer.File, content []byte) bool {
+	if f.Ownership != renderer.OwnerManaged {
+		return false
 	}
+	_, ok := renderer.ManagedBlock(content)
+	return !ok
+}
 
-	var applied []string
-	for _, diff := range plan.Diffs {
-		if diff.Status == "unchanged" && !includeUnchanged {
+// Resolve re-merges the conflicting files with a strategy: ours keeps the
+// local side of each conflict, theirs takes the template side. Markers an
+// earlier update left in a file are resolved the same way.
+func (p *Plan) Resolve(strategy string) {
+	if strategy == StrategyMarkers {
+		return
+	}
+	for i := range p.Diffs {
+		diff := &p.Diffs[i]
+		if diff.Status != "conflict" {
 			continue
 		}
-		applied = append(applied, diff.Path)
+		diff.New, _ = Merge(diff.Base, diff.Current, diff.file.Content, strategy)
+		diff.New, _ = ResolveMarkers(diff.New, strategy)
+		diff.NewHash = hashContent(diff.New)
+		// Still a change even when ours leaves the content as is: applying
+		// it moves the merge base on, so the conflict is not raised again
+		diff.Status = "merged"
+		p.Conflicted--
+		p.Merged++
 	}
+}
 
-	// RenderAll already wrote the files, so just return what changed
-	_ = files
+// Changes returns the diffs that would change a file: new and modified.
+func (p *Plan) Changes() []Diff {
+	var changes []Diff
+	for _, diff := range p.Diffs {
+		if diff.Status != "unchanged" {
+			changes = append(changes, diff)
+		}
+	}
+	return changes
+}
+
+// Filter keeps the diffs matching at least one of the only patterns (all
+// diffs when there are none) and none of the exclude patterns. See Match
+// for the pattern syntax.
+func Filter(diffs []Diff, only, exclude []string) []Diff {
+	var kept []Diff
+	for _, diff := range diffs {
+		if len(only) > 0 && !matchAny(only, diff.Path) {
+			continue
+		}
+		if matchAny(exclude, diff.Path) {
+			continue
+		}
+		kept = append(kept, diff)
+	}
+	return kept
+}
+
+// Match reports whether a project-relative path matches a glob pattern.
+// Patterns without a slash match the file name in any directory, a
+// trailing "/**" matches everything below a directory, and other patterns
+// match the whole path with filepath.Match.
+func Match(pattern, path string) bool {
+	pattern = filepath.ToSlash(pattern)
+	path = filepath.ToSlash(path)
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return path == dir || strings.HasPrefix(path, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(path))
+		return ok
+	}
+	ok, _ := filepath.Match(pattern, path)
+	return ok
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply writes the planned content of the given diffs, deletes the
+// removed ones, and updates the manifest and merge bases to match. It
+// returns the paths that were written or deleted, also when it fails
+// partway; those are recorded in the manifest either way.
+func Apply(projectDir string, cfg *config.ProjectConfig, diffs []Diff) ([]string, error) {
+	manifest, err := renderer.LoadManifest(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var write, rendered []renderer.File
+	var removed []string
+	for _, diff := range diffs {
+		switch {
+		case diff.file.Path == "":
+			// Not from ComputePlan; nothing known to write
+		case diff.Status == "removed":
+			removed = append(removed, diff.file.Path)
+		default:
+			write = append(write, renderer.File{Path: diff.file.Path, Content: diff.New})
+			rendered = append(rendered, diff.file)
+		}
+	}
+
+	applied, writeErr := renderer.WriteFiles(projectDir, write)
+	if err := manifest.Record(projectDir, rendered[:len(applied)]); err != nil {
+		return applied, errors.Join(writeErr, err)
+	}
+	if writeErr != nil {
+		return applied, errors.Join(writeErr, manifest.Save(projectDir))
+	}
+
+	for _, path := range removed {
+		if err := os.Remove(resolveRealPath(projectDir, cfg, path)); err != nil && !os.IsNotExist(err) {
+			err = fmt.Errorf("removing %s: %w", path, err)
+			return applied, errors.Join(err, manifest.Save(projectDir))
+		}
+		manifest.Remove(projectDir, path)
+		applied = append(applied, path)
+	}
+
+	if err := manifest.Save(projectDir); err != nil {
+		return nil, err
+	}
 	return applied, nil
 }
 
@@ -118,9 +291,9 @@ func resolveRealPath(projectDir string, cfg *config.ProjectConfig, relPath strin
 	return filepath.Join(projectDir, relPath)
 }
 
-func hashFile(path string) string {
-	data, err := os.ReadFile(path)
-	if err != nil {
+// hashContent returns a short content hash, or "" for a missing file.
+func hashContent(data []byte) string {
+	if data == nil {
 		return ""
 	}
 	h := sha256.Sum256(data)